@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/render"
+	"github.com/hellodword/suno-radio/internal/httperr"
+	"github.com/hellodword/suno-radio/internal/suno"
+	"golang.org/x/net/websocket"
+)
+
+const eventsKeepAlive = time.Second * 15
+
+// Events pushes the now-playing changes over SSE, or over WebSocket if the client upgrades.
+func Events(pool *suno.WorkerPool, logger *slog.Logger) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		worker := getWorker(pool, w, r)
+		if worker == nil {
+			return
+		}
+
+		if strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
+			// the cors middleware allows any origin, so the origin is not checked
+			websocket.Server{Handler: func(conn *websocket.Conn) {
+				err := eventsWebSocket(worker, conn)
+				logger.DebugContext(r.Context(), "Events websocket", "err", err)
+			}}.ServeHTTP(w, r)
+			return
+		}
+
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			_ = render.Render(w, r, httperr.ErrHTTPStatus(http.StatusInternalServerError, errors.New("streaming unsupported")))
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("X-Accel-Buffering", "no")
+
+		send := func(event *suno.Event) error {
+			b, err := json.Marshal(event)
+			if err != nil {
+				return err
+			}
+			_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Type, b)
+			flusher.Flush()
+			return err
+		}
+
+		err := subscribe(r.Context(), worker, send, func() error {
+			_, err := io.WriteString(w, ": keepalive\n\n")
+			flusher.Flush()
+			return err
+		})
+		if errors.Is(err, suno.ErrWorkerClosed) {
+			_ = render.Render(w, r, httperr.ErrHTTPStatus(http.StatusNotFound, err))
+			return
+		}
+		logger.DebugContext(r.Context(), "Events", "err", err)
+	}
+}
+
+func eventsWebSocket(worker *suno.Worker, conn *websocket.Conn) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// the client doesn't send anything, reading detects the close
+	go func() {
+		defer cancel()
+		_, _ = io.Copy(io.Discard, conn)
+	}()
+
+	return subscribe(ctx, worker, func(event *suno.Event) error {
+		return websocket.JSON.Send(conn, event)
+	}, nil)
+}
+
+// subscribe sends the current state and the following events of the worker until
+// the ctx is done or the worker is closed.
+func subscribe(ctx context.Context, worker *suno.Worker, send func(*suno.Event) error, keepAlive func() error) error {
+	current, listener, err := worker.Subscribe()
+	if err != nil {
+		return err
+	}
+	defer listener.Close()
+
+	for _, event := range current {
+		if err := send(event); err != nil {
+			return err
+		}
+	}
+
+	ticker := time.NewTicker(eventsKeepAlive)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case event, ok := <-listener.Ch():
+			if !ok {
+				return nil
+			}
+			if err := send(event); err != nil {
+				return err
+			}
+		case <-ticker.C:
+			if keepAlive == nil {
+				continue
+			}
+			if err := keepAlive(); err != nil {
+				return err
+			}
+		}
+	}
+}
@@ -5,14 +5,19 @@ import (
 	"crypto/subtle"
 	"errors"
 	"flag"
+	"fmt"
+	"io"
 	"io/fs"
 	"log/slog"
 	"net/http"
 	"os"
 	"os/signal"
 	"path/filepath"
+	"reflect"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
@@ -24,9 +29,12 @@ import (
 	"github.com/hellodword/suno-radio/internal/common"
 	"github.com/hellodword/suno-radio/internal/config"
 	"github.com/hellodword/suno-radio/internal/httperr"
+	"github.com/hellodword/suno-radio/internal/mp3"
 	"github.com/hellodword/suno-radio/internal/mp3toogg"
+	"github.com/hellodword/suno-radio/internal/mpegts"
 	"github.com/hellodword/suno-radio/internal/ogg"
 	"github.com/hellodword/suno-radio/internal/suno"
+	"github.com/hellodword/suno-radio/internal/webm"
 	"github.com/jub0bs/cors"
 )
 
@@ -53,9 +61,13 @@ func main() {
 		os.Exit(0)
 	}
 
-	conf, err := config.LoadFromYaml(*configPath)
+	conf, err := loadConfig(*configPath)
 	if err != nil {
-		panic(err)
+		logger.Error("invalid config", "p", *configPath)
+		for _, line := range strings.Split(err.Error(), "\n") {
+			logger.Error("invalid config", "err", line)
+		}
+		os.Exit(1)
 	}
 
 	err = loggerLevel.UnmarshalText([]byte(conf.LogLevel))
@@ -63,6 +75,10 @@ func main() {
 		panic(err)
 	}
 
+	if conf.Path == "" {
+		logger.Warn("config not found, using the defaults and the environment", "p", *configPath)
+	}
+
 	os.MkdirAll(conf.DataDir, 0755)
 
 	err = mp3toogg.MP3ToOggInit(conf.RPC)
@@ -82,47 +98,74 @@ func main() {
 	var wg sync.WaitGroup
 	var errC = make(chan error)
 
-	pool := suno.NewWorkerPool(logger, time.Minute*30, conf.DataDir)
+	client, err := suno.NewClient(conf.Suno.BaseURL, conf.Suno.Proxy, conf.Suno.UserAgent)
+	if err != nil {
+		panic(err)
+	}
+	client.PlaylistTimeout = conf.Suno.PlaylistTimeout
+	client.DownloadTimeout = conf.Suno.DownloadTimeout
+	client.Retry = &suno.RetryPolicy{
+		MaxAttempts: conf.Suno.RetryAttempts,
+		BaseDelay:   conf.Suno.RetryBaseDelay,
+		MaxDelay:    conf.Suno.RetryMaxDelay,
+	}
+	client.Limiter = suno.NewLimiter(conf.Suno.RateLimit, conf.Suno.RateBurst)
 
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
+	pool := suno.NewWorkerPool(logger, client, conf.PlaylistInterval, *conf.PlaylistMaxClips, conf.DataDir)
 
-		for i := range *conf.Playlist {
-			logger.Info("pool adding", "playlist", (*conf.Playlist)[i])
+	var auth atomic.Value
+	auth.Store(conf.Auth)
 
-			var id, alias string
-			arr := strings.SplitN((*conf.Playlist)[i], "/", 2)
-			alias = arr[0]
+	var reloadMu sync.Mutex
+	reload := func() error {
+		reloadMu.Lock()
+		defer reloadMu.Unlock()
 
-			if !validateAlias(alias) {
-				logger.Error("invalid playlist alias", "playlist", (*conf.Playlist)[i])
-				continue
-			}
+		logger.Info("reloading config", "p", *configPath)
+		newConf, err := loadConfig(*configPath)
+		if err != nil {
+			return err
+		}
 
-			if len(arr) == 2 {
-				id = arr[1]
-			} else {
-				if _, ok := suno.PlayListPreset[alias]; ok {
-					id = suno.PlayListPreset[alias]
-				}
-			}
+		err = loggerLevel.UnmarshalText([]byte(newConf.LogLevel))
+		if err != nil {
+			return err
+		}
 
-			if len(id) != common.UUIDLength {
-				logger.Error("invalid playlist id", "playlist", (*conf.Playlist)[i])
-				continue
-			}
+		if newConf.Addr != conf.Addr || newConf.DataDir != conf.DataDir || newConf.RPC != conf.RPC {
+			logger.Warn("addr, data_dir and rpc require a restart")
+		}
 
-			err := pool.Add(ctx, id, alias)
-			if err != nil {
-				logger.Error("pool add", "playlist", (*conf.Playlist)[i], "err", err)
-				if os.IsPermission(err) || os.IsNotExist(err) {
-					errC <- err
-					return
-				}
-			}
+		if !reflect.DeepEqual(newConf.Suno, conf.Suno) {
+			logger.Warn("suno requires a restart")
+		}
+
+		pool.SetDefaults(newConf.PlaylistInterval, *newConf.PlaylistMaxClips)
+
+		auth.Store(newConf.Auth)
+
+		err = pool.Sync(ctx, playlistEntries(newConf))
+		if err != nil {
+			return err
+		}
+
+		logger.Info("reloaded config", "p", *configPath)
+		return nil
+	}
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
 
-			logger.Info("pool added", "playlist", (*conf.Playlist)[i], "id", id, "alias", alias)
+		err := pool.Sync(ctx, playlistEntries(conf))
+		if errors.Is(err, fs.ErrPermission) || errors.Is(err, fs.ErrNotExist) {
+			errC <- err
+			return
+		}
+
+		err = pool.Restore(ctx)
+		if err != nil {
+			logger.Error("pool restore", "err", err)
 		}
 	}()
 
@@ -136,8 +179,9 @@ func main() {
 			http.MethodPatch,
 			http.MethodDelete,
 		},
-		RequestHeaders: []string{"*"},
-		Credentialed:   false,
+		RequestHeaders:  []string{"*"},
+		ResponseHeaders: []string{sessionHeader},
+		Credentialed:    false,
 	})
 	if err != nil {
 		panic(err)
@@ -161,10 +205,21 @@ func main() {
 		r.Route("/playlist", func(r chi.Router) {
 			r.Get("/", GetPlaylists(pool, logger))
 			r.Get("/{id}", Radio(pool, logger))
-			if conf.Auth != "" {
-				r.With(Auth(conf.Auth)).Put("/{id}/{alias}", AddPlaylist(ctx, pool, logger))
-				r.With(Auth(conf.Auth)).Delete("/{id}", RemovePlaylist(pool, logger))
-			}
+			r.Get("/{id}/queue", Queue(pool, logger))
+			r.Get("/{id}/history", History(pool, logger))
+			r.Get("/{id}/events", Events(pool, logger))
+			r.Get("/{id}/hls/index.m3u8", HLSPlaylist(pool, logger))
+			r.Get("/{id}/hls/{segment}", HLSSegment(pool, logger))
+			r.Post("/{id}/vote", Vote(pool, logger))
+			// the auth may be set by reloading, so always route them
+			r.With(Auth(&auth)).Put("/{id}/{alias}", AddPlaylist(ctx, pool, logger))
+			r.With(Auth(&auth)).Delete("/{id}", RemovePlaylist(pool, logger))
+			r.With(Auth(&auth)).Post("/{id}/skip", Skip(pool, logger))
+			r.With(Auth(&auth)).Post("/{id}/queue", Enqueue(pool, logger))
+			r.With(Auth(&auth)).Delete("/{id}/queue/{clipID}", Dequeue(pool, logger))
+		})
+		r.Route("/admin", func(r chi.Router) {
+			r.With(Auth(&auth)).Post("/reload", Reload(reload, pool, logger))
 		})
 	})
 
@@ -187,9 +242,20 @@ func main() {
 
 	exit := make(chan os.Signal, 1)
 	signal.Notify(exit, os.Interrupt, syscall.SIGTERM)
-	select {
-	case <-exit:
-	case err = <-errC:
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+loop:
+	for {
+		select {
+		case <-hup:
+			if err := reload(); err != nil {
+				logger.Error("reload", "err", err)
+			}
+		case <-exit:
+			break loop
+		case err = <-errC:
+			break loop
+		}
 	}
 	if server != nil {
 		// server.Shutdown(ctx)
@@ -212,11 +278,16 @@ func GetPlaylists(pool *suno.WorkerPool, logger *slog.Logger) func(w http.Respon
 	}
 }
 
+// Radio streams the ogg by default, or the format of /{id}.{format} and ?format={format},
+// one of ogg, mp3 and webm.
 func Radio(pool *suno.WorkerPool, logger *slog.Logger) func(w http.ResponseWriter, r *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
-		id := chi.URLParam(r, "id")
+		id, format, _ := strings.Cut(chi.URLParam(r, "id"), ".")
+		if q := r.URL.Query().Get("format"); q != "" {
+			format = q
+		}
 
-		if !validateAlias(id) && !validateUUID(id) {
+		if !common.ValidateAlias(id) && !common.ValidateUUID(id) {
 			_ = render.Render(w, r, httperr.ErrHTTPStatus(http.StatusBadRequest, nil))
 			return
 		}
@@ -230,9 +301,44 @@ func Radio(pool *suno.WorkerPool, logger *slog.Logger) func(w http.ResponseWrite
 		logger.DebugContext(r.Context(), "Radio", "id", id)
 		worker := pool.Get(id)
 
-		w.Header().Set("Content-Type", ogg.MIMEType)
+		session := getSession(r)
+		if session == "" {
+			session = uuid.NewString()
+		}
+
+		var stream func(string, context.Context, io.Writer) error
+		switch format {
+		case "", "ogg":
+			stream = worker.Stream
+			w.Header().Set("Content-Type", ogg.MIMEType)
+		case "mp3":
+			stream = worker.StreamMP3
+			w.Header().Set("Content-Type", mp3.MIMEType)
+		case "webm":
+			stream = worker.StreamWebM
+			w.Header().Set("Content-Type", webm.MIMEType)
+		default:
+			_ = render.Render(w, r, httperr.ErrHTTPStatus(http.StatusBadRequest, nil))
+			return
+		}
+		w.Header().Set(sessionHeader, session)
+
+		// the ogg and webm players would take the metadata blocks for the audio
+		var writer io.Writer = w
+		if format == "mp3" && icy(worker, w, r) {
+			writer = suno.NewIcyWriter(w, suno.DefaultIcyMetaInt)
+		}
 
-		if err := worker.Stream(r.RemoteAddr, r.Context(), w); err != nil {
+		if err := stream(session, r.Context(), writer); err != nil {
+			if errors.Is(err, suno.ErrTooManyListeners) {
+				_ = render.Render(w, r, httperr.ErrHTTPStatus(http.StatusServiceUnavailable, err))
+				return
+			}
+			// removed meanwhile
+			if errors.Is(err, suno.ErrWorkerClosed) {
+				_ = render.Render(w, r, httperr.ErrHTTPStatus(http.StatusNotFound, err))
+				return
+			}
 			// logger.ErrorContext(r.Context(), "Radio", "id", id, "err", err)
 			logger.DebugContext(r.Context(), "Radio", "id", id, "err", err)
 			// _ = render.Render(w, r, types.ErrHTTPStatus(http.StatusInternalServerError, err))
@@ -241,20 +347,266 @@ func Radio(pool *suno.WorkerPool, logger *slog.Logger) func(w http.ResponseWrite
 	}
 }
 
+// icy sets the ICY headers for the internet radio apps,
+// and reports whether the client wants the metadata interleaved.
+func icy(worker *suno.Worker, w http.ResponseWriter, r *http.Request) bool {
+	info := worker.PlaylistInfo()
+
+	name := info.Name
+	if name == "" {
+		name = worker.Alias()
+	}
+
+	w.Header().Set("icy-name", name)
+	if info.Description != "" {
+		w.Header().Set("icy-description", info.Description)
+	}
+	w.Header().Set("icy-url", info.URL())
+
+	if strings.TrimSpace(r.Header.Get("Icy-MetaData")) != "1" {
+		return false
+	}
+
+	w.Header().Set("icy-metaint", strconv.Itoa(suno.DefaultIcyMetaInt))
+	return true
+}
+
+// getWorker renders the error if the playlist is not found.
+func getWorker(pool *suno.WorkerPool, w http.ResponseWriter, r *http.Request) *suno.Worker {
+	id := chi.URLParam(r, "id")
+
+	if !common.ValidateAlias(id) && !common.ValidateUUID(id) {
+		_ = render.Render(w, r, httperr.ErrHTTPStatus(http.StatusBadRequest, nil))
+		return nil
+	}
+
+	worker := pool.Get(id)
+	if worker == nil {
+		_ = render.Render(w, r, httperr.ErrHTTPStatus(http.StatusBadRequest, nil))
+		return nil
+	}
+
+	return worker
+}
+
+func Skip(pool *suno.WorkerPool, logger *slog.Logger) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		worker := getWorker(pool, w, r)
+		if worker == nil {
+			return
+		}
+
+		err := worker.Skip()
+		if err != nil {
+			logger.DebugContext(r.Context(), "Skip", "err", err)
+			_ = render.Render(w, r, httperr.ErrHTTPStatus(http.StatusConflict, err))
+			return
+		}
+
+		render.JSON(w, r, worker.Info())
+	}
+}
+
+const sessionHeader = "SUNO-RADIO-SESSION"
+
+// getSession returns the session of the listener from the header or the query,
+// the browsers can't set the header on an audio element.
+func getSession(r *http.Request) string {
+	session := strings.TrimSpace(r.Header.Get(sessionHeader))
+	if session == "" {
+		session = r.URL.Query().Get("session")
+	}
+
+	if !common.ValidateUUID(session) {
+		return ""
+	}
+	return session
+}
+
+// Vote votes to skip the current clip for the streaming session.
+func Vote(pool *suno.WorkerPool, logger *slog.Logger) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		worker := getWorker(pool, w, r)
+		if worker == nil {
+			return
+		}
+
+		session := getSession(r)
+		if session == "" {
+			_ = render.Render(w, r, httperr.ErrHTTPStatus(http.StatusBadRequest, nil))
+			return
+		}
+
+		result, err := worker.Vote(session)
+		if err != nil {
+			logger.DebugContext(r.Context(), "Vote", "err", err)
+			status := http.StatusConflict
+			switch {
+			case errors.Is(err, suno.ErrVotingDisabled):
+				status = http.StatusForbidden
+			case errors.Is(err, suno.ErrSessionNotFound):
+				status = http.StatusNotFound
+			}
+			_ = render.Render(w, r, httperr.ErrHTTPStatus(status, err))
+			return
+		}
+
+		render.JSON(w, r, result)
+	}
+}
+
+type EnqueueRequest struct {
+	ClipID string `json:"clip_id"`
+}
+
+func Enqueue(pool *suno.WorkerPool, logger *slog.Logger) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		worker := getWorker(pool, w, r)
+		if worker == nil {
+			return
+		}
+
+		var req EnqueueRequest
+		err := render.DecodeJSON(r.Body, &req)
+		if err != nil || !common.ValidateUUID(req.ClipID) {
+			_ = render.Render(w, r, httperr.ErrHTTPStatus(http.StatusBadRequest, err))
+			return
+		}
+
+		err = worker.Enqueue(req.ClipID)
+		if err != nil {
+			logger.DebugContext(r.Context(), "Enqueue", "err", err)
+			_ = render.Render(w, r, httperr.ErrHTTPStatus(http.StatusNotFound, err))
+			return
+		}
+
+		render.JSON(w, r, worker.Upcoming(10))
+	}
+}
+
+func Dequeue(pool *suno.WorkerPool, logger *slog.Logger) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		worker := getWorker(pool, w, r)
+		if worker == nil {
+			return
+		}
+
+		err := worker.Dequeue(chi.URLParam(r, "clipID"))
+		if err != nil {
+			logger.DebugContext(r.Context(), "Dequeue", "err", err)
+			_ = render.Render(w, r, httperr.ErrHTTPStatus(http.StatusNotFound, err))
+			return
+		}
+
+		render.JSON(w, r, worker.Upcoming(10))
+	}
+}
+
+func Queue(pool *suno.WorkerPool, logger *slog.Logger) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		worker := getWorker(pool, w, r)
+		if worker == nil {
+			return
+		}
+
+		n, err := strconv.Atoi(r.URL.Query().Get("n"))
+		if err != nil || n <= 0 {
+			n = 10
+		}
+		n = min(n, 50)
+
+		render.JSON(w, r, worker.Upcoming(n))
+	}
+}
+
+func HLSPlaylist(pool *suno.WorkerPool, logger *slog.Logger) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		worker := getWorker(pool, w, r)
+		if worker == nil {
+			return
+		}
+
+		b, err := worker.HLSPlaylist(r.Context())
+		if err != nil {
+			logger.DebugContext(r.Context(), "HLSPlaylist", "err", err)
+			status := http.StatusServiceUnavailable
+			if errors.Is(err, suno.ErrWorkerClosed) {
+				status = http.StatusNotFound
+			}
+			_ = render.Render(w, r, httperr.ErrHTTPStatus(status, err))
+			return
+		}
+
+		w.Header().Set("Content-Type", suno.HLSPlaylistMIMEType)
+		w.Header().Set("Cache-Control", "no-cache")
+		_, _ = w.Write(b)
+	}
+}
+
+func HLSSegment(pool *suno.WorkerPool, logger *slog.Logger) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		worker := getWorker(pool, w, r)
+		if worker == nil {
+			return
+		}
+
+		seq, ok := strings.CutSuffix(chi.URLParam(r, "segment"), ".ts")
+		n, err := strconv.ParseInt(seq, 10, 64)
+		if !ok || err != nil {
+			_ = render.Render(w, r, httperr.ErrHTTPStatus(http.StatusBadRequest, err))
+			return
+		}
+
+		b, err := worker.HLSSegment(n)
+		if err != nil {
+			logger.DebugContext(r.Context(), "HLSSegment", "err", err)
+			status := http.StatusNotFound
+			if errors.Is(err, suno.ErrTooManyListeners) {
+				status = http.StatusServiceUnavailable
+			}
+			_ = render.Render(w, r, httperr.ErrHTTPStatus(status, err))
+			return
+		}
+
+		w.Header().Set("Content-Type", mpegts.MIMEType)
+		_, _ = w.Write(b)
+	}
+}
+
+func History(pool *suno.WorkerPool, logger *slog.Logger) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		worker := getWorker(pool, w, r)
+		if worker == nil {
+			return
+		}
+
+		n, err := strconv.Atoi(r.URL.Query().Get("n"))
+		if err != nil || n <= 0 {
+			n = 20
+		}
+		n = min(n, 200)
+
+		render.JSON(w, r, worker.History(n))
+	}
+}
+
 func AddPlaylist(ctx context.Context, pool *suno.WorkerPool, logger *slog.Logger) func(w http.ResponseWriter, r *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
 		id := chi.URLParam(r, "id")
 		alias := strings.ToLower(chi.URLParam(r, "alias"))
 
-		if !validateAlias(alias) && !validateUUID(id) {
+		if !common.ValidateAlias(alias) || !common.ValidateUUID(id) {
 			_ = render.Render(w, r, httperr.ErrHTTPStatus(http.StatusBadRequest, nil))
 			return
 		}
 
-		found := pool.Contains(id) || pool.Contains(alias)
-		if !found {
+		if !pool.Contains(id) {
 			// the r.Context() wont work for this, pass the ctx from func main
-			err := pool.Add(ctx, id, alias)
+			err := pool.Register(ctx, id, alias, suno.PlaylistOptions{})
+			if errors.Is(err, suno.ErrAliasConflict) {
+				_ = render.Render(w, r, httperr.ErrHTTPStatus(http.StatusConflict, err))
+				return
+			}
 			if err != nil {
 				logger.ErrorContext(r.Context(), "AddPlaylist", "err", err)
 				_ = render.Render(w, r, httperr.ErrHTTPStatus(http.StatusInternalServerError, err))
@@ -275,12 +627,14 @@ func RemovePlaylist(pool *suno.WorkerPool, logger *slog.Logger) func(w http.Resp
 	return func(w http.ResponseWriter, r *http.Request) {
 		id := chi.URLParam(r, "id")
 
-		if !validateAlias(id) && !validateUUID(id) {
+		if !common.ValidateAlias(id) && !common.ValidateUUID(id) {
 			_ = render.Render(w, r, httperr.ErrHTTPStatus(http.StatusBadRequest, nil))
 			return
 		}
 
-		err := pool.Remove(id)
+		purge, _ := strconv.ParseBool(r.URL.Query().Get("purge"))
+
+		err := pool.Remove(id, purge)
 		if err != nil {
 			logger.ErrorContext(r.Context(), "RemovePlaylist", "err", err)
 			_ = render.Render(w, r, httperr.ErrHTTPStatus(http.StatusInternalServerError, err))
@@ -296,12 +650,32 @@ func RemovePlaylist(pool *suno.WorkerPool, logger *slog.Logger) func(w http.Resp
 	}
 }
 
-func Auth(auth string) func(http.Handler) http.Handler {
+func Reload(reload func() error, pool *suno.WorkerPool, logger *slog.Logger) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		err := reload()
+		if err != nil {
+			logger.ErrorContext(r.Context(), "Reload", "err", err)
+			_ = render.Render(w, r, httperr.ErrHTTPStatus(http.StatusInternalServerError, err))
+			return
+		}
+
+		infos := pool.Infos()
+		if err := render.Render(w, r, infos); err != nil {
+			logger.ErrorContext(r.Context(), "Reload", "err", err)
+			_ = render.Render(w, r, httperr.ErrHTTPStatus(http.StatusUnprocessableEntity, err))
+			return
+		}
+	}
+}
+
+// Auth rejects everything while the auth is empty.
+func Auth(authV *atomic.Value) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			auth, _ := authV.Load().(string)
 			rauth := strings.TrimSpace(r.Header.Get("SUNO-RADIO-AUTH"))
 
-			if subtle.ConstantTimeCompare([]byte(auth), []byte(rauth)) != 1 {
+			if auth == "" || subtle.ConstantTimeCompare([]byte(auth), []byte(rauth)) != 1 {
 				_ = render.Render(w, r, httperr.ErrHTTPStatus(http.StatusUnauthorized, nil))
 				return
 			}
@@ -311,33 +685,68 @@ func Auth(auth string) func(http.Handler) http.Handler {
 	}
 }
 
-func getDist() (http.FileSystem, error) {
-	fsys, err := fs.Sub(frontend.Dist, "dist")
+// loadConfig also checks the playlist options which depend on the suno package.
+func loadConfig(p string) (*config.ServerConfig, error) {
+	conf, err := config.LoadFromYaml(p)
 	if err != nil {
 		return nil, err
 	}
-	return http.FS(fsys), nil
-}
 
-func validateUUID(s string) bool {
-	if len(s) != 36 {
-		return false
+	var errs []error
+	for i, playlist := range *conf.Playlist {
+		field := fmt.Sprintf("playlist[%d] %q", i, playlist.String())
+
+		if playlist.Order != "" && !suno.ValidOrder(playlist.Order) {
+			errs = append(errs, fmt.Errorf("%s: unknown order %q, want one of %s", field, playlist.Order, strings.Join(suno.Orders(), ", ")))
+		}
+
+		if playlist.Visibility != "" && playlist.Visibility != suno.VisibilityPublic && playlist.Visibility != suno.VisibilityUnlisted {
+			errs = append(errs, fmt.Errorf("%s: visibility %q is not one of %s, %s", field, playlist.Visibility, suno.VisibilityPublic, suno.VisibilityUnlisted))
+		}
+
+		if playlist.Crossfade > 0 && !suno.CrossfadeSupported() {
+			errs = append(errs, fmt.Errorf("%s: crossfade: %w", field, suno.ErrCrossfadeUnsupported))
+		}
 	}
 
-	u, err := uuid.Parse(s)
-	return err == nil && u.String() == s
+	err = errors.Join(errs...)
+	if err != nil {
+		return nil, err
+	}
+
+	return conf, nil
 }
 
-func validateAlias(alias string) bool {
-	if len(alias) < 3 || len(alias) > 32 {
-		return false
+func playlistEntries(conf *config.ServerConfig) []*suno.PlaylistEntry {
+	var entries []*suno.PlaylistEntry
+
+	// validated by loadConfig
+	for _, playlist := range *conf.Playlist {
+		entries = append(entries, &suno.PlaylistEntry{ID: playlist.ID, Alias: playlist.Alias, Options: suno.PlaylistOptions{
+			Interval:       playlist.Interval,
+			MaxClips:       playlist.MaxClips,
+			Order:          playlist.Order,
+			NoRepeatSongs:  playlist.NoRepeatSongs,
+			NoRepeatWithin: playlist.NoRepeatWithin,
+			Gain:           playlist.Gain,
+			MaxListeners:   playlist.MaxListeners,
+			Visibility:     playlist.Visibility,
+			SkipVoteRatio:  playlist.SkipVoteRatio,
+			Chained:        playlist.Chained,
+			Gap:            playlist.Gap,
+			TargetLUFS:     playlist.TargetLUFS,
+			Crossfade:      playlist.Crossfade,
+			TrimSilence:    playlist.TrimSilence,
+		}})
 	}
 
-	for _, b := range []byte(alias) {
-		if !(('a' <= b && b <= 'z') || ('0' <= b && b <= '9') || b == '_' || b == '-') {
-			return false
-		}
-	}
+	return entries
+}
 
-	return true
+func getDist() (http.FileSystem, error) {
+	fsys, err := fs.Sub(frontend.Dist, "dist")
+	if err != nil {
+		return nil, err
+	}
+	return http.FS(fsys), nil
 }
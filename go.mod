@@ -9,6 +9,7 @@ require (
 	github.com/jub0bs/cors v0.2.0
 	github.com/teivah/broadcast v0.1.0
 	github.com/u2takey/ffmpeg-go v0.5.0
+	golang.org/x/net v0.25.0
 	gopkg.in/hraban/opus.v2 v2.0.0-20230925203106-0188a62cb302
 	sigs.k8s.io/yaml v1.4.0
 )
@@ -19,6 +20,5 @@ require (
 	github.com/jmespath/go-jmespath v0.4.0 // indirect
 	github.com/stretchr/testify v1.7.1 // indirect
 	github.com/u2takey/go-utils v0.3.1 // indirect
-	golang.org/x/net v0.25.0 // indirect
 	golang.org/x/text v0.15.0 // indirect
 )
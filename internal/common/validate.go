@@ -0,0 +1,27 @@
+package common
+
+import "github.com/google/uuid"
+
+func ValidateUUID(s string) bool {
+	if len(s) != UUIDLength {
+		return false
+	}
+
+	u, err := uuid.Parse(s)
+	return err == nil && u.String() == s
+}
+
+// ValidateAlias accepts [0-9a-z_-]{3,32}
+func ValidateAlias(alias string) bool {
+	if len(alias) < 3 || len(alias) > 32 {
+		return false
+	}
+
+	for _, b := range []byte(alias) {
+		if !(('a' <= b && b <= 'z') || ('0' <= b && b <= '9') || b == '_' || b == '-') {
+			return false
+		}
+	}
+
+	return true
+}
@@ -1,25 +1,149 @@
 package config
 
 import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/url"
 	"os"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/hellodword/suno-radio/internal/common"
 	yaml "sigs.k8s.io/yaml/goyaml.v3"
 )
 
 type ServerConfig struct {
-	LogLevel    string    `yaml:"log_level"`
-	Addr        string    `yaml:"addr"`
-	DataDir     string    `yaml:"data_dir"`
-	Auth        string    `yaml:"auth"`
-	Cloudflared *bool     `yaml:"cloudflared"`
-	RPC         string    `yaml:"rpc"`
-	Playlist    *[]string `yaml:"playlist"`
+	// where it's loaded from, empty if the file doesn't exist
+	Path string `yaml:"-"`
+
+	LogLevel string `yaml:"log_level"`
+	Addr     string `yaml:"addr"`
+	DataDir  string `yaml:"data_dir"`
+	Auth     string `yaml:"auth"`
+	// overrides the auth, for the docker secrets
+	AuthFile    string            `yaml:"auth_file"`
+	Cloudflared *bool             `yaml:"cloudflared"`
+	RPC         string            `yaml:"rpc"`
+	Playlist    *[]PlaylistConfig `yaml:"playlist"`
+	// max clips fetched per playlist, 0 means unlimited
+	PlaylistMaxClips *int `yaml:"playlist_max_clips"`
+	// the refresh interval of the playlists without their own
+	PlaylistInterval time.Duration `yaml:"playlist_interval"`
+	Suno             *SunoConfig   `yaml:"suno"`
+}
+
+// PlaylistConfig is either the "alias/uuid" or "alias" shorthand, or an object.
+type PlaylistConfig struct {
+	// empty for the presets
+	ID    string `yaml:"id"`
+	Alias string `yaml:"alias"`
+	// refresh interval
+	Interval time.Duration `yaml:"interval"`
+	// shuffle, random, playlist, reverse, upvote, newest or least-played
+	Order string `yaml:"order"`
+	// a clip is not repeated within the songs or the duration if possible
+	NoRepeatSongs  int           `yaml:"no_repeat_songs"`
+	NoRepeatWithin time.Duration `yaml:"no_repeat_within"`
+	// in dB, written to the OpusHead output gain
+	Gain         float64 `yaml:"gain"`
+	MaxClips     int     `yaml:"max_clips"`
+	MaxListeners int     `yaml:"max_listeners"`
+	// public or unlisted, the unlisted ones are hidden from the playlist api
+	Visibility string `yaml:"visibility"`
+	// the fraction of the listeners needed to skip a clip, 0 disables the voting
+	SkipVoteRatio float64 `yaml:"skip_vote_ratio"`
+	// every clip starts a new logical ogg stream with its title, so the players show it,
+	// it's also needed for the gapless playback, the pre-skip and the end padding of the clips are only trimmed then
+	Chained bool `yaml:"chained"`
+	// the silence between the clips
+	Gap time.Duration `yaml:"gap"`
+	// the integrated loudness in LUFS the clips are normalized to, 0 disables it, it needs the chained stream
+	TargetLUFS float64 `yaml:"target_lufs"`
+	// the overlap of the clips, it needs a binary built with the opus tag
+	Crossfade time.Duration `yaml:"crossfade"`
+	// drop the leading and trailing silence of the clips
+	TrimSilence bool `yaml:"trim_silence"`
+}
+
+func (c *PlaylistConfig) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode {
+		var s string
+		err := value.Decode(&s)
+		if err != nil {
+			return err
+		}
+
+		*c = PlaylistConfig{}
+		c.Alias, c.ID, _ = strings.Cut(s, "/")
+		return nil
+	}
+
+	// the node decoder doesn't inherit KnownFields
+	var unknown []string
+	if value.Kind == yaml.MappingNode {
+		t := reflect.TypeOf(*c)
+		for i := 0; i+1 < len(value.Content); i += 2 {
+			key := value.Content[i]
+			if !slices.ContainsFunc(reflect.VisibleFields(t), func(f reflect.StructField) bool {
+				return f.Tag.Get("yaml") == key.Value
+			}) {
+				unknown = append(unknown, fmt.Sprintf("line %d: field %s not found in type %s", key.Line, key.Value, t))
+			}
+		}
+	}
+
+	// avoid the recursion
+	type plain PlaylistConfig
+	err := value.Decode((*plain)(c))
+	if err != nil {
+		return err
+	}
+
+	if len(unknown) > 0 {
+		return &yaml.TypeError{Errors: unknown}
+	}
+
+	return nil
+}
+
+func (c PlaylistConfig) String() string {
+	if c.ID == "" {
+		return c.Alias
+	}
+	return c.Alias + "/" + c.ID
+}
+
+type SunoConfig struct {
+	// point it to a fake suno server for testing
+	BaseURL   string `yaml:"base_url"`
+	Proxy     string `yaml:"proxy"`
+	UserAgent string `yaml:"user_agent"`
+	// negative means no timeout
+	PlaylistTimeout time.Duration `yaml:"playlist_timeout"`
+	DownloadTimeout time.Duration `yaml:"download_timeout"`
+	// attempts per request, including the first one
+	RetryAttempts  int           `yaml:"retry_attempts"`
+	RetryBaseDelay time.Duration `yaml:"retry_base_delay"`
+	RetryMaxDelay  time.Duration `yaml:"retry_max_delay"`
+	// requests per second across all the playlists, negative means no limit
+	RateLimit float64 `yaml:"rate_limit"`
+	RateBurst int     `yaml:"rate_burst"`
 }
 
 func boolPtr(b bool) *bool {
 	return &b
 }
 
+func intPtr(i int) *int {
+	return &i
+}
+
 // TODO do this with tag?
 var defaultServerConfig = ServerConfig{
 	LogLevel:    "info",
@@ -28,29 +152,77 @@ var defaultServerConfig = ServerConfig{
 	Auth:        "",
 	Cloudflared: boolPtr(true),
 	RPC:         "127.0.0.1:3001",
-	Playlist: &[]string{
-		"trending",
+	Playlist: &[]PlaylistConfig{
+		{Alias: "trending"},
+	},
+	PlaylistMaxClips: intPtr(500),
+	PlaylistInterval: time.Minute * 30,
+	Suno: &SunoConfig{
+		BaseURL:         "https://studio-api.suno.ai",
+		PlaylistTimeout: time.Second * 30,
+		DownloadTimeout: time.Minute * 5,
+		RetryAttempts:   5,
+		RetryBaseDelay:  time.Second,
+		RetryMaxDelay:   time.Minute,
+		RateLimit:       2,
+		RateBurst:       4,
 	},
 }
 
+// LoadFromYaml loads the config, the environment variables override the file,
+// the defaults are used if the file doesn't exist.
+// All the problems are reported at once.
 func LoadFromYaml(p string) (*ServerConfig, error) {
+	var s ServerConfig
+	var errs []error
+
 	f, err := os.Open(p)
-	if err != nil {
-		if os.IsNotExist(err) {
-			return &defaultServerConfig, nil
-		} else {
+	if err == nil {
+		defer f.Close()
+
+		d := yaml.NewDecoder(f)
+		d.KnownFields(true)
+		err = d.Decode(&s)
+
+		// the unknown keys and bad values don't stop the decoding
+		var typeErr *yaml.TypeError
+		if errors.As(err, &typeErr) {
+			for _, e := range typeErr.Errors {
+				errs = append(errs, errors.New(e))
+			}
+		} else if err != nil && !errors.Is(err, io.EOF) {
 			return nil, err
 		}
+
+		s.Path = p
+	} else if !os.IsNotExist(err) {
+		return nil, err
 	}
-	defer f.Close()
 
-	var s ServerConfig
-	d := yaml.NewDecoder(f)
-	err = d.Decode(&s)
+	errs = append(errs, s.applyEnv(os.LookupEnv))
+
+	if s.AuthFile != "" {
+		b, err := os.ReadFile(s.AuthFile)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("auth_file: %w", err))
+		} else {
+			s.Auth = strings.TrimSpace(string(b))
+		}
+	}
+
+	s.applyDefaults()
+
+	errs = append(errs, s.Validate())
+
+	err = errors.Join(errs...)
 	if err != nil {
 		return nil, err
 	}
 
+	return &s, nil
+}
+
+func (s *ServerConfig) applyDefaults() {
 	if s.Addr == "" {
 		s.Addr = defaultServerConfig.Addr
 	}
@@ -64,7 +236,7 @@ func LoadFromYaml(p string) (*ServerConfig, error) {
 	}
 
 	if s.Cloudflared == nil {
-		s.Cloudflared = defaultServerConfig.Cloudflared
+		s.Cloudflared = boolPtr(*defaultServerConfig.Cloudflared)
 	}
 
 	if s.RPC == "" {
@@ -72,8 +244,268 @@ func LoadFromYaml(p string) (*ServerConfig, error) {
 	}
 
 	if s.Playlist == nil {
-		s.Playlist = defaultServerConfig.Playlist
+		playlist := slices.Clone(*defaultServerConfig.Playlist)
+		s.Playlist = &playlist
 	}
 
-	return &s, nil
+	for i := range *s.Playlist {
+		playlist := &(*s.Playlist)[i]
+		if playlist.ID == "" {
+			playlist.ID = common.PlayListPreset[playlist.Alias]
+		}
+	}
+
+	if s.PlaylistMaxClips == nil {
+		s.PlaylistMaxClips = intPtr(*defaultServerConfig.PlaylistMaxClips)
+	}
+
+	if s.PlaylistInterval == 0 {
+		s.PlaylistInterval = defaultServerConfig.PlaylistInterval
+	}
+
+	if s.Suno == nil {
+		s.Suno = &SunoConfig{}
+	}
+
+	if s.Suno.BaseURL == "" {
+		s.Suno.BaseURL = defaultServerConfig.Suno.BaseURL
+	}
+
+	if s.Suno.PlaylistTimeout == 0 {
+		s.Suno.PlaylistTimeout = defaultServerConfig.Suno.PlaylistTimeout
+	}
+
+	if s.Suno.DownloadTimeout == 0 {
+		s.Suno.DownloadTimeout = defaultServerConfig.Suno.DownloadTimeout
+	}
+
+	if s.Suno.RetryAttempts == 0 {
+		s.Suno.RetryAttempts = defaultServerConfig.Suno.RetryAttempts
+	}
+
+	if s.Suno.RetryBaseDelay == 0 {
+		s.Suno.RetryBaseDelay = defaultServerConfig.Suno.RetryBaseDelay
+	}
+
+	if s.Suno.RetryMaxDelay == 0 {
+		s.Suno.RetryMaxDelay = defaultServerConfig.Suno.RetryMaxDelay
+	}
+
+	if s.Suno.RateLimit == 0 {
+		s.Suno.RateLimit = defaultServerConfig.Suno.RateLimit
+	}
+
+	if s.Suno.RateBurst == 0 {
+		s.Suno.RateBurst = defaultServerConfig.Suno.RateBurst
+	}
+}
+
+const EnvPrefix = "SUNO_RADIO_"
+
+// applyEnv reads the SUNO_RADIO_* variables, e.g. SUNO_RADIO_AUTH,
+// SUNO_RADIO_PLAYLIST takes the comma separated shorthands.
+func (s *ServerConfig) applyEnv(lookup func(string) (string, bool)) error {
+	parseBool := func(v string) (*bool, error) {
+		b, err := strconv.ParseBool(v)
+		return &b, err
+	}
+
+	parseInt := func(v string) (*int, error) {
+		i, err := strconv.Atoi(v)
+		return &i, err
+	}
+
+	sunoConfig := func() *SunoConfig {
+		if s.Suno == nil {
+			s.Suno = &SunoConfig{}
+		}
+		return s.Suno
+	}
+
+	setters := []struct {
+		key string
+		set func(v string) error
+	}{
+		{"LOG_LEVEL", func(v string) error { s.LogLevel = v; return nil }},
+		{"ADDR", func(v string) error { s.Addr = v; return nil }},
+		{"DATA_DIR", func(v string) error { s.DataDir = v; return nil }},
+		{"AUTH", func(v string) error { s.Auth = v; return nil }},
+		{"AUTH_FILE", func(v string) error { s.AuthFile = v; return nil }},
+		{"CLOUDFLARED", func(v string) (err error) { s.Cloudflared, err = parseBool(v); return }},
+		{"RPC", func(v string) error { s.RPC = v; return nil }},
+		{"PLAYLIST", func(v string) error {
+			var playlist []PlaylistConfig
+			for _, shorthand := range strings.Split(v, ",") {
+				shorthand = strings.TrimSpace(shorthand)
+				if shorthand == "" {
+					continue
+				}
+				var c PlaylistConfig
+				c.Alias, c.ID, _ = strings.Cut(shorthand, "/")
+				playlist = append(playlist, c)
+			}
+			s.Playlist = &playlist
+			return nil
+		}},
+		{"PLAYLIST_MAX_CLIPS", func(v string) (err error) { s.PlaylistMaxClips, err = parseInt(v); return }},
+		{"SUNO_BASE_URL", func(v string) error { sunoConfig().BaseURL = v; return nil }},
+		{"SUNO_PROXY", func(v string) error { sunoConfig().Proxy = v; return nil }},
+		{"SUNO_USER_AGENT", func(v string) error { sunoConfig().UserAgent = v; return nil }},
+	}
+
+	var errs []error
+	for _, setter := range setters {
+		v, ok := lookup(EnvPrefix + setter.key)
+		if !ok {
+			continue
+		}
+
+		err := setter.set(v)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s%s: %w", EnvPrefix, setter.key, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func validateAddr(addr string) error {
+	_, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return err
+	}
+
+	n, err := strconv.ParseUint(port, 10, 16)
+	if err != nil {
+		return fmt.Errorf("invalid port %q", port)
+	}
+
+	if n == 0 {
+		return fmt.Errorf("invalid port %q", port)
+	}
+
+	return nil
+}
+
+// maxGap keeps a typo from turning the station into dead air.
+const maxGap = time.Minute
+
+// the range of the target_lufs, the streaming services aim at -14 and EBU R128 at -23
+const (
+	minTargetLUFS = -40
+	maxTargetLUFS = -5
+)
+
+// maxCrossfade bounds the decoding and the re-encoding of a transition.
+const maxCrossfade = time.Second * 15
+
+// Validate reports all the problems at once, the defaults are expected to be applied.
+// The order, the visibility and the crossfade support are left to the suno package.
+func (s *ServerConfig) Validate() error {
+	var errs []error
+	report := func(format string, a ...any) {
+		errs = append(errs, fmt.Errorf(format, a...))
+	}
+
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(s.LogLevel)); err != nil {
+		report("log_level: %q is not one of debug, info, warn, error", s.LogLevel)
+	}
+
+	if err := validateAddr(s.Addr); err != nil {
+		report("addr: %q: %w", s.Addr, err)
+	}
+
+	if err := validateAddr(s.RPC); err != nil {
+		report("rpc: %q: %w", s.RPC, err)
+	}
+
+	if s.PlaylistMaxClips != nil && *s.PlaylistMaxClips < 0 {
+		report("playlist_max_clips: %d is negative", *s.PlaylistMaxClips)
+	}
+
+	if s.PlaylistInterval < 0 {
+		report("playlist_interval: %s is negative", s.PlaylistInterval)
+	}
+
+	aliases := make(map[string]int)
+	ids := make(map[string]int)
+	for i, playlist := range *s.Playlist {
+		field := fmt.Sprintf("playlist[%d] %q", i, playlist.String())
+
+		if !common.ValidateAlias(playlist.Alias) {
+			report("%s: alias %q must match [0-9a-z_-]{3,32}", field, playlist.Alias)
+		} else if j, ok := aliases[playlist.Alias]; ok {
+			report("%s: alias %q duplicates playlist[%d]", field, playlist.Alias, j)
+		} else {
+			aliases[playlist.Alias] = i
+		}
+
+		if playlist.ID == "" {
+			report("%s: id is required, %q is not a preset", field, playlist.Alias)
+		} else if !common.ValidateUUID(playlist.ID) {
+			report("%s: id %q is not a lowercase uuid", field, playlist.ID)
+		} else if j, ok := ids[playlist.ID]; ok {
+			report("%s: id %q duplicates playlist[%d]", field, playlist.ID, j)
+		} else {
+			ids[playlist.ID] = i
+		}
+
+		if playlist.Interval < 0 {
+			report("%s: interval %s is negative", field, playlist.Interval)
+		}
+
+		if playlist.NoRepeatSongs < 0 {
+			report("%s: no_repeat_songs %d is negative", field, playlist.NoRepeatSongs)
+		}
+
+		if playlist.NoRepeatWithin < 0 {
+			report("%s: no_repeat_within %s is negative", field, playlist.NoRepeatWithin)
+		}
+
+		if playlist.MaxListeners < 0 {
+			report("%s: max_listeners %d is negative", field, playlist.MaxListeners)
+		}
+
+		if playlist.Gap < 0 || playlist.Gap > maxGap {
+			report("%s: gap %s is not within [0, %s]", field, playlist.Gap, maxGap)
+		}
+
+		switch {
+		case playlist.TargetLUFS == 0:
+		case playlist.TargetLUFS < minTargetLUFS || playlist.TargetLUFS > maxTargetLUFS:
+			report("%s: target_lufs %v is not within [%d, %d]", field, playlist.TargetLUFS, minTargetLUFS, maxTargetLUFS)
+		case !playlist.Chained:
+			report("%s: target_lufs needs chained, the output gain is per logical stream", field)
+		}
+
+		switch {
+		case playlist.Crossfade < 0 || playlist.Crossfade > maxCrossfade:
+			report("%s: crossfade %s is not within [0, %s]", field, playlist.Crossfade, maxCrossfade)
+		case playlist.Crossfade > 0 && playlist.Gap > 0:
+			report("%s: gap and crossfade are exclusive", field)
+		}
+
+		if playlist.SkipVoteRatio < 0 || playlist.SkipVoteRatio > 1 {
+			report("%s: skip_vote_ratio %v is not within [0, 1]", field, playlist.SkipVoteRatio)
+		}
+	}
+
+	if s.Suno != nil {
+		if u, err := url.Parse(s.Suno.BaseURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			report("suno.base_url: %q is not a http(s) url", s.Suno.BaseURL)
+		}
+
+		if s.Suno.Proxy != "" {
+			if u, err := url.Parse(s.Suno.Proxy); err != nil || u.Scheme == "" || u.Host == "" {
+				report("suno.proxy: %q is not a url", s.Suno.Proxy)
+			}
+		}
+
+		if s.Suno.RetryBaseDelay > s.Suno.RetryMaxDelay {
+			report("suno.retry_base_delay: %s is greater than retry_max_delay %s", s.Suno.RetryBaseDelay, s.Suno.RetryMaxDelay)
+		}
+	}
+
+	return errors.Join(errs...)
 }
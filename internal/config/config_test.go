@@ -0,0 +1,137 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoadPlaylist(t *testing.T) {
+	p := filepath.Join(t.TempDir(), "server.yml")
+	err := os.WriteFile(p, []byte(`
+playlist:
+  - trending/1190bf92-10dc-4ce5-968a-7a377f37f984
+  - weekly
+  - id: 6713d315-3541-460d-8788-162cce241336
+    alias: lofi
+    interval: 1h
+    order: playlist
+    gain: -3.5
+    max_clips: 100
+    max_listeners: 10
+    visibility: unlisted
+`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conf, err := LoadFromYaml(p)
+	if err != nil {
+		t.Fatal("unexpected LoadFromYaml error:", err)
+	}
+
+	expect := []PlaylistConfig{
+		{ID: "1190bf92-10dc-4ce5-968a-7a377f37f984", Alias: "trending"},
+		// the preset id
+		{ID: "08a079b2-a63b-4f9c-9f29-de3c1864ddef", Alias: "weekly"},
+		{
+			ID:           "6713d315-3541-460d-8788-162cce241336",
+			Alias:        "lofi",
+			Interval:     time.Hour,
+			Order:        "playlist",
+			Gain:         -3.5,
+			MaxClips:     100,
+			MaxListeners: 10,
+			Visibility:   "unlisted",
+		},
+	}
+
+	if len(*conf.Playlist) != len(expect) {
+		t.Fatalf("got %d playlists, expected %d", len(*conf.Playlist), len(expect))
+	}
+
+	for i := range expect {
+		if (*conf.Playlist)[i] != expect[i] {
+			t.Fatalf("playlist %d:\n%+v\n%+v", i, (*conf.Playlist)[i], expect[i])
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	p := filepath.Join(t.TempDir(), "server.yml")
+	err := os.WriteFile(p, []byte(`
+log_level: verbose
+addr: "0.0.0.0"
+unknown_key: 1
+playlist:
+  - Trending/1190bf92-10dc-4ce5-968a-7a377f37f984
+  - foo
+  - bar/1190BF92-10DC-4CE5-968A-7A377F37F984
+  - weekly
+  - alias: weekly
+    id: 08a079b2-a63b-4f9c-9f29-de3c1864ddef
+    gap: 2h
+  - alias: fade
+    crossfade: 5s
+    target_lufs: -14
+  - alias: lofi
+    colour: red
+`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = LoadFromYaml(p)
+	if err == nil {
+		t.Fatal("expected LoadFromYaml error")
+	}
+
+	expects := []string{
+		"unknown_key not found",
+		"colour not found",
+		`log_level: "verbose"`,
+		`addr: "0.0.0.0"`,
+		`alias "Trending" must match`,
+		`"foo" is not a preset`,
+		`is not a lowercase uuid`,
+		`alias "weekly" duplicates playlist[3]`,
+		`id "08a079b2-a63b-4f9c-9f29-de3c1864ddef" duplicates playlist[3]`,
+		`gap 2h0m0s is not within`,
+		`target_lufs needs chained`,
+	}
+
+	for _, expect := range expects {
+		if !strings.Contains(err.Error(), expect) {
+			t.Errorf("missing %q in:\n%s", expect, err)
+		}
+	}
+}
+
+func TestEnv(t *testing.T) {
+	dir := t.TempDir()
+	authFile := filepath.Join(dir, "auth")
+	err := os.WriteFile(authFile, []byte("secret\n"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv("SUNO_RADIO_ADDR", "127.0.0.1:4000")
+	t.Setenv("SUNO_RADIO_AUTH_FILE", authFile)
+	t.Setenv("SUNO_RADIO_CLOUDFLARED", "false")
+	t.Setenv("SUNO_RADIO_PLAYLIST", "trending, lofi")
+
+	conf, err := LoadFromYaml(filepath.Join(dir, "server.yml"))
+	if err != nil {
+		t.Fatal("unexpected LoadFromYaml error:", err)
+	}
+
+	if conf.Path != "" || conf.Addr != "127.0.0.1:4000" || conf.Auth != "secret" || *conf.Cloudflared {
+		t.Fatalf("unexpected config %+v", conf)
+	}
+
+	if len(*conf.Playlist) != 2 || (*conf.Playlist)[1].ID != "6713d315-3541-460d-8788-162cce241336" {
+		t.Fatalf("unexpected playlist %+v", *conf.Playlist)
+	}
+}
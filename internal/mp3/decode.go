@@ -0,0 +1,230 @@
+// Package mp3 splits MP3 files into frames without decoding them,
+// so the frames of several files can be concatenated into one stream.
+package mp3
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"time"
+)
+
+const MIMEType = "audio/mpeg"
+
+const (
+	Version25 = 0
+	Version2  = 2
+	Version1  = 3
+)
+
+const (
+	Layer3 = 1
+	Layer2 = 2
+	Layer1 = 3
+)
+
+var (
+	bitrates = map[[2]int][16]int{
+		{Version1, Layer1}: {0, 32, 64, 96, 128, 160, 192, 224, 256, 288, 320, 352, 384, 416, 448, -1},
+		{Version1, Layer2}: {0, 32, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320, 384, -1},
+		{Version1, Layer3}: {0, 32, 40, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320, -1},
+		{Version2, Layer1}: {0, 32, 48, 56, 64, 80, 96, 112, 128, 144, 160, 176, 192, 224, 256, -1},
+		{Version2, Layer2}: {0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160, -1},
+		{Version2, Layer3}: {0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160, -1},
+	}
+
+	sampleRates = map[int][3]int{
+		Version1:  {44100, 48000, 32000},
+		Version2:  {22050, 24000, 16000},
+		Version25: {11025, 12000, 8000},
+	}
+)
+
+// Header is the 4 bytes frame header.
+type Header uint32
+
+func (h Header) Version() int    { return int(h>>19) & 0b11 }
+func (h Header) Layer() int      { return int(h>>17) & 0b11 }
+func (h Header) Protected() bool { return (h>>16)&1 == 0 }
+func (h Header) Padding() bool   { return (h>>9)&1 == 1 }
+func (h Header) Mono() bool      { return (h>>6)&0b11 == 0b11 }
+
+// Bitrate in kbps.
+func (h Header) Bitrate() int {
+	version := h.Version()
+	if version == Version25 {
+		version = Version2
+	}
+	return bitrates[[2]int{version, h.Layer()}][(h>>12)&0b1111]
+}
+
+func (h Header) SampleRate() int {
+	i := (h >> 10) & 0b11
+	if i == 0b11 {
+		return 0
+	}
+	return sampleRates[h.Version()][i]
+}
+
+// Samples per channel in the frame.
+func (h Header) Samples() int {
+	switch {
+	case h.Layer() == Layer1:
+		return 384
+	case h.Layer() == Layer3 && h.Version() != Version1:
+		return 576
+	default:
+		return 1152
+	}
+}
+
+func (h Header) Duration() time.Duration {
+	return time.Duration(h.Samples()) * time.Second / time.Duration(h.SampleRate())
+}
+
+// Valid reports whether the header is complete enough to get the frame length,
+// the free format bitrate is not supported.
+func (h Header) Valid() bool {
+	return h>>21 == 0x7ff &&
+		h.Version() != 1 && h.Layer() != 0 &&
+		h.Bitrate() > 0 && h.SampleRate() > 0
+}
+
+// Len is the frame length including the header.
+func (h Header) Len() int {
+	padding := 0
+	if h.Padding() {
+		padding = 1
+	}
+
+	if h.Layer() == Layer1 {
+		return (12*h.Bitrate()*1000/h.SampleRate() + padding) * 4
+	}
+	return h.Samples()/8*h.Bitrate()*1000/h.SampleRate() + padding
+}
+
+// sideInfoLen is where the Xing or Info tag starts after the header.
+func (h Header) sideInfoLen() int {
+	n := 0
+	switch {
+	case h.Version() == Version1 && h.Mono():
+		n = 17
+	case h.Version() == Version1:
+		n = 32
+	case h.Mono():
+		n = 9
+	default:
+		n = 17
+	}
+	if h.Protected() {
+		n += 2
+	}
+	return n
+}
+
+// Frame is a whole MP3 frame, the header included.
+type Frame struct {
+	Header Header
+	Data   []byte
+}
+
+// IsInfo reports whether the frame is a Xing, Info or VBRI frame,
+// which describes the whole file and is silent.
+func (f *Frame) IsInfo() bool {
+	if f.Header.Layer() != Layer3 {
+		return false
+	}
+
+	off := 4 + f.Header.sideInfoLen()
+	if len(f.Data) >= off+4 {
+		tag := f.Data[off : off+4]
+		if bytes.Equal(tag, []byte("Xing")) || bytes.Equal(tag, []byte("Info")) {
+			return true
+		}
+	}
+
+	return len(f.Data) >= 36+4 && bytes.Equal(f.Data[36:40], []byte("VBRI"))
+}
+
+// A Decoder reads the audio frames of a MP3 file,
+// skipping the ID3 tags, the Xing/Info frames and the garbage between the frames.
+type Decoder struct {
+	r *bufio.Reader
+}
+
+func NewDecoder(r io.Reader) *Decoder {
+	return &Decoder{r: bufio.NewReader(r)}
+}
+
+const (
+	id3v1Len       = 128
+	id3v2HeaderLen = 10
+)
+
+// Decode returns the next audio frame, the Data is owned by the caller.
+func (d *Decoder) Decode() (*Frame, error) {
+	for {
+		b, err := d.r.Peek(4)
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil, io.EOF
+			}
+			return nil, err
+		}
+
+		switch {
+		case bytes.HasPrefix(b, []byte("ID3")):
+			err = d.skipID3v2()
+		case bytes.HasPrefix(b, []byte("TAG")):
+			_, err = d.r.Discard(id3v1Len)
+		default:
+			h := Header(binary.BigEndian.Uint32(b))
+			if !h.Valid() {
+				_, err = d.r.Discard(1)
+				break
+			}
+
+			f := &Frame{Header: h, Data: make([]byte, h.Len())}
+			_, err = io.ReadFull(d.r, f.Data)
+			if err != nil {
+				// a truncated last frame
+				if errors.Is(err, io.ErrUnexpectedEOF) {
+					return nil, io.EOF
+				}
+				return nil, err
+			}
+
+			if f.IsInfo() {
+				continue
+			}
+			return f, nil
+		}
+
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil, io.EOF
+			}
+			return nil, err
+		}
+	}
+}
+
+func (d *Decoder) skipID3v2() error {
+	b, err := d.r.Peek(id3v2HeaderLen)
+	if err != nil {
+		return err
+	}
+
+	// syncsafe integer
+	size := int(b[6]&0x7f)<<21 | int(b[7]&0x7f)<<14 | int(b[8]&0x7f)<<7 | int(b[9]&0x7f)
+	size += id3v2HeaderLen
+	// footer present
+	if b[5]&0x10 != 0 {
+		size += id3v2HeaderLen
+	}
+
+	_, err = d.r.Discard(size)
+	return err
+}
@@ -0,0 +1,91 @@
+package mp3
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"testing"
+	"time"
+)
+
+// MPEG1 Layer III, 128kbps, 44100Hz, stereo, no CRC
+const testHeader = Header(0xfffb9000)
+
+func testFrame(fill byte) []byte {
+	b := bytes.Repeat([]byte{fill}, testHeader.Len())
+	binary.BigEndian.PutUint32(b, uint32(testHeader))
+	return b
+}
+
+func TestHeader(t *testing.T) {
+	h := testHeader
+	if !h.Valid() {
+		t.Fatal("invalid")
+	}
+	if h.Version() != Version1 || h.Layer() != Layer3 || h.Bitrate() != 128 || h.SampleRate() != 44100 {
+		t.Fatalf("version %d layer %d bitrate %d sample rate %d", h.Version(), h.Layer(), h.Bitrate(), h.SampleRate())
+	}
+	if h.Len() != 417 {
+		t.Fatalf("len %d", h.Len())
+	}
+	if h.Duration() != 1152*time.Second/44100 {
+		t.Fatalf("duration %s", h.Duration())
+	}
+
+	// padding
+	if (h | 1<<9).Len() != 418 {
+		t.Fatalf("padded len %d", (h | 1<<9).Len())
+	}
+
+	// MPEG2 Layer III, 64kbps, 24000Hz
+	h = Header(0xfff38400)
+	if !h.Valid() || h.Samples() != 576 || h.Len() != 576/8*64000/24000 {
+		t.Fatalf("mpeg2 samples %d len %d", h.Samples(), h.Len())
+	}
+
+	for _, h := range []Header{0, 0xffe00000, 0xfffb0000, 0xfffbf000, 0xfffb9c00} {
+		if h.Valid() {
+			t.Fatalf("%08x is valid", uint32(h))
+		}
+	}
+}
+
+func TestDecoder(t *testing.T) {
+	var file bytes.Buffer
+
+	// ID3v2 with 20 bytes of frames
+	file.Write([]byte{'I', 'D', '3', 4, 0, 0, 0, 0, 0, 20})
+	file.Write(bytes.Repeat([]byte{0xff}, 20))
+
+	info := testFrame(0)
+	copy(info[4+32:], "Info")
+	file.Write(info)
+
+	for i := range 3 {
+		file.Write(testFrame(byte(i + 1)))
+	}
+	file.WriteString("garbage")
+	for i := range 2 {
+		file.Write(testFrame(byte(i + 4)))
+	}
+
+	id3v1 := make([]byte, id3v1Len)
+	copy(id3v1, "TAG")
+	file.Write(id3v1)
+
+	d := NewDecoder(&file)
+	for i := range 5 {
+		f, err := d.Decode()
+		if err != nil {
+			t.Fatalf("frame %d: %v", i, err)
+		}
+		if f.Header != testHeader || len(f.Data) != testHeader.Len() || f.Data[4] != byte(i+1) {
+			t.Fatalf("frame %d: header %08x len %d data %d", i, uint32(f.Header), len(f.Data), f.Data[4])
+		}
+	}
+
+	if _, err := d.Decode(); !errors.Is(err, io.EOF) {
+		t.Fatalf("after the last frame: %v", err)
+	}
+}
@@ -0,0 +1,88 @@
+package mp3toogg
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// the ebur128 filter passes the audio through, its summary is logged when it's done
+const loudnessFilter = "ebur128=framelog=quiet"
+
+var (
+	ErrNoLoudness = errors.New("no loudness summary")
+
+	reIntegrated = regexp.MustCompile(`\bI:\s+(-?[0-9.]+|-inf) LUFS`)
+	reRange      = regexp.MustCompile(`\bLRA:\s+([0-9.]+) LU\b`)
+)
+
+// Loudness is the EBU R128 analysis of a clip, it's saved next to the ogg.
+type Loudness struct {
+	// the integrated loudness in LUFS
+	Integrated float64 `json:"integrated"`
+	// the loudness range in LU
+	Range float64 `json:"range"`
+}
+
+func LoudnessPath(pogg string) string {
+	return strings.TrimSuffix(pogg, ".ogg") + ".loudness.json"
+}
+
+// parseLoudness reads the summary of the ebur128 filter in the ffmpeg log.
+func parseLoudness(log string) (*Loudness, error) {
+	integrated := reIntegrated.FindAllStringSubmatch(log, -1)
+	if len(integrated) == 0 {
+		return nil, ErrNoLoudness
+	}
+
+	l := &Loudness{}
+	// the summary comes last, the silence is -70 LUFS at most
+	s := integrated[len(integrated)-1][1]
+	if s == "-inf" {
+		l.Integrated = -70
+	} else {
+		v, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return nil, err
+		}
+		l.Integrated = v
+	}
+
+	if lra := reRange.FindAllStringSubmatch(log, -1); len(lra) > 0 {
+		l.Range, _ = strconv.ParseFloat(lra[len(lra)-1][1], 64)
+	}
+
+	return l, nil
+}
+
+func writeLoudness(pogg string, l *Loudness) error {
+	b, err := json.Marshal(l)
+	if err != nil {
+		return err
+	}
+
+	tmp := LoudnessPath(pogg) + ".tmp"
+	err = os.WriteFile(tmp, b, 0644)
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp, LoudnessPath(pogg))
+}
+
+// ReadLoudness reads the analysis of the ogg, the clips converted before it have none.
+func ReadLoudness(pogg string) (*Loudness, error) {
+	b, err := os.ReadFile(LoudnessPath(pogg))
+	if err != nil {
+		return nil, err
+	}
+
+	var l Loudness
+	err = json.Unmarshal(b, &l)
+	if err != nil {
+		return nil, err
+	}
+	return &l, nil
+}
@@ -0,0 +1,63 @@
+package mp3toogg
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+const ebur128Log = `[Parsed_ebur128_0 @ 0x5581] t: 9.9   TARGET:-23 LUFS    M: -15.2 S: -16.1     I: -16.4 LUFS       LRA:   4.1 LU
+[Parsed_ebur128_0 @ 0x5581] Summary:
+
+  Integrated loudness:
+    I:         -14.7 LUFS
+    Threshold: -25.0 LUFS
+
+  Loudness range:
+    LRA:         6.3 LU
+    Threshold: -35.1 LUFS
+    LRA low:   -19.2 LUFS
+    LRA high:  -12.9 LUFS
+`
+
+func TestParseLoudness(t *testing.T) {
+	l, err := parseLoudness(ebur128Log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l.Integrated != -14.7 || l.Range != 6.3 {
+		t.Fatalf("loudness %+v", l)
+	}
+
+	l, err = parseLoudness("    I:         -inf LUFS\n")
+	if err != nil || l.Integrated != -70 {
+		t.Fatalf("silence %+v %v", l, err)
+	}
+
+	_, err = parseLoudness("conversion failed")
+	if !errors.Is(err, ErrNoLoudness) {
+		t.Fatalf("no summary: %v", err)
+	}
+}
+
+func TestLoudnessSidecar(t *testing.T) {
+	pogg := filepath.Join(t.TempDir(), "clip.ogg")
+
+	_, err := ReadLoudness(pogg)
+	if err == nil {
+		t.Fatal("expected ReadLoudness error")
+	}
+
+	err = writeLoudness(pogg, &Loudness{Integrated: -9.5, Range: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	l, err := ReadLoudness(pogg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *l != (Loudness{Integrated: -9.5, Range: 3}) {
+		t.Fatalf("loudness %+v", l)
+	}
+}
@@ -27,6 +27,7 @@ func (t *MP3ToOgg) Convert(args *MP3ToOggArgs, reply *string) error {
 	pogg := path.Join("data", args.Playlist, fmt.Sprintf("%s.ogg", args.ClipID))
 
 	os.Remove(pogg)
+	os.Remove(LoudnessPath(pogg))
 
 	_, err := ConvertMP3ToOgg(pmp3, pogg)
 	if err != nil {
@@ -147,6 +148,7 @@ func ConvertMP3ToOgg(src, dst string) (string, error) {
 			}).
 			Output(tmp, ffmpeg_go.KwArgs{
 				"c:a":     "libopus",
+				"af":      loudnessFilter,
 				"threads": "1",
 				// "map_metadata": "-1",
 			}).
@@ -162,5 +164,18 @@ func ConvertMP3ToOgg(src, dst string) (string, error) {
 	}
 
 	err = os.Rename(tmp, dst)
-	return buf.String(), err
+	if err != nil {
+		return buf.String(), err
+	}
+
+	// the clip plays without the normalization if the analysis is missing
+	l, err := parseLoudness(buf.String())
+	if err == nil {
+		err = writeLoudness(dst, l)
+	}
+	if err != nil {
+		fmt.Fprintf(buf, "loudness: %v\n", err)
+	}
+
+	return buf.String(), nil
 }
@@ -0,0 +1,214 @@
+// Package mpegts muxes a single audio elementary stream into MPEG transport stream packets,
+// just enough for the HLS segments.
+package mpegts
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+const MIMEType = "video/mp2t"
+
+const (
+	PacketSize = 188
+
+	pidPAT   = 0x0000
+	pidPMT   = 0x1000
+	pidAudio = 0x0100
+
+	programNumber = 1
+	streamIDAudio = 0xc0
+
+	// StreamTypeMPEG1Audio is for the MPEG-1 layer I/II/III frames
+	StreamTypeMPEG1Audio = 0x03
+	// StreamTypeMPEG2Audio is for the MPEG-2 (LSF) layer I/II/III frames
+	StreamTypeMPEG2Audio = 0x04
+
+	// ClockRate of the PTS
+	ClockRate = 90000
+
+	// pcrDelay puts the PTS ahead of the PCR like the ffmpeg muxer does, so a frame arrives before it is due
+	pcrDelay = ClockRate / 10
+)
+
+// the PES_packet_length is 16 bits, and it counts the 8 bytes of the optional header
+const maxPESPayload = 0xffff - 8
+
+var ErrPESTooLarge = errors.New("pes payload too large")
+
+// Muxer writes the PAT, the PMT and the PES packets of one audio stream.
+type Muxer struct {
+	w          io.Writer
+	streamType byte
+	cc         map[uint16]byte
+	buf        [PacketSize]byte
+}
+
+func NewMuxer(w io.Writer, streamType byte) *Muxer {
+	return &Muxer{w: w, streamType: streamType, cc: make(map[uint16]byte)}
+}
+
+// WriteTables writes the PAT and the PMT, every segment should start with them.
+func (m *Muxer) WriteTables() error {
+	pat := []byte{
+		0x00,       // table_id
+		0xb0, 0x0d, // section_syntax_indicator, section_length 13
+		0x00, 0x01, // transport_stream_id
+		0xc1,       // version 0, current_next_indicator
+		0x00, 0x00, // section_number, last_section_number
+		byte(programNumber >> 8), byte(programNumber & 0xff),
+		0xe0 | byte(pidPMT>>8), byte(pidPMT & 0xff),
+	}
+	err := m.writeSection(pidPAT, pat)
+	if err != nil {
+		return err
+	}
+
+	pmt := []byte{
+		0x02,       // table_id
+		0xb0, 0x12, // section_syntax_indicator, section_length 18
+		byte(programNumber >> 8), byte(programNumber & 0xff),
+		0xc1,       // version 0, current_next_indicator
+		0x00, 0x00, // section_number, last_section_number
+		0xe0 | byte(pidAudio>>8), byte(pidAudio & 0xff), // PCR_PID
+		0xf0, 0x00, // program_info_length
+		m.streamType,
+		0xe0 | byte(pidAudio>>8), byte(pidAudio & 0xff),
+		0xf0, 0x00, // ES_info_length
+	}
+	return m.writeSection(pidPMT, pmt)
+}
+
+func (m *Muxer) writeSection(pid uint16, section []byte) error {
+	section = binary.BigEndian.AppendUint32(section, crc32(section))
+
+	// pointer_field
+	payload := append([]byte{0x00}, section...)
+	return m.writePackets(pid, payload, -1)
+}
+
+// WritePES writes the payload as a PES packet with the pts in ClockRate,
+// the PCR is 100ms before the pts.
+func (m *Muxer) WritePES(pts int64, payload []byte) error {
+	if len(payload) > maxPESPayload {
+		return ErrPESTooLarge
+	}
+
+	pes := make([]byte, 0, 14+len(payload))
+	pes = append(pes, 0x00, 0x00, 0x01, streamIDAudio)
+	pes = binary.BigEndian.AppendUint16(pes, uint16(len(payload)+8))
+	// marker bits, PTS only, PES_header_data_length 5
+	pes = append(pes, 0x80, 0x80, 0x05)
+	pes = appendTimestamp(pes, 0x2, pts)
+	pes = append(pes, payload...)
+
+	return m.writePackets(pidAudio, pes, max(pts-pcrDelay, 0))
+}
+
+func appendTimestamp(b []byte, prefix byte, ts int64) []byte {
+	return append(b,
+		prefix<<4|byte(ts>>29)&0x0e|1,
+		byte(ts>>22),
+		byte(ts>>14)|1,
+		byte(ts>>7),
+		byte(ts<<1)|1,
+	)
+}
+
+// writePackets splits the payload into packets, the first one carries the PCR if pcr >= 0,
+// the last one is padded by the adaptation field.
+func (m *Muxer) writePackets(pid uint16, payload []byte, pcr int64) error {
+	start := true
+	for len(payload) > 0 || start {
+		p := m.buf[:0]
+
+		cc := m.cc[pid]
+		m.cc[pid] = (cc + 1) & 0x0f
+
+		var adaptation []byte
+		if start && pcr >= 0 {
+			// random_access_indicator, PCR_flag
+			adaptation = []byte{0x50}
+			base := pcr & (1<<33 - 1)
+			adaptation = append(adaptation,
+				byte(base>>25), byte(base>>17), byte(base>>9), byte(base>>1),
+				byte(base<<7)|0x7e, 0x00,
+			)
+		}
+
+		space := PacketSize - 4
+		if adaptation != nil {
+			space -= 1 + len(adaptation)
+		}
+
+		if len(payload) < space {
+			// stuffing, an empty adaptation field is only its length
+			if adaptation == nil {
+				adaptation = []byte{}
+				space--
+				if len(payload) < space {
+					adaptation = append(adaptation, 0x00)
+					space--
+				}
+			}
+			for len(payload) < space {
+				adaptation = append(adaptation, 0xff)
+				space--
+			}
+		}
+
+		pusi := byte(0)
+		if start {
+			pusi = 0x40
+		}
+
+		control := byte(0x10)
+		if adaptation != nil {
+			control = 0x30
+		}
+
+		p = append(p, 0x47, pusi|byte(pid>>8)&0x1f, byte(pid), control|cc)
+		if adaptation != nil {
+			p = append(p, byte(len(adaptation)))
+			p = append(p, adaptation...)
+		}
+
+		n := PacketSize - len(p)
+		p = append(p, payload[:n]...)
+		payload = payload[n:]
+
+		_, err := m.w.Write(p)
+		if err != nil {
+			return err
+		}
+
+		start = false
+	}
+
+	return nil
+}
+
+var crcTable = func() (t [256]uint32) {
+	for i := range t {
+		crc := uint32(i) << 24
+		for range 8 {
+			if crc&0x80000000 != 0 {
+				crc = crc<<1 ^ 0x04c11db7
+			} else {
+				crc <<= 1
+			}
+		}
+		t[i] = crc
+	}
+	return
+}()
+
+// crc32 is the CRC-32/MPEG-2 of the PSI sections.
+func crc32(b []byte) uint32 {
+	crc := uint32(0xffffffff)
+	for _, v := range b {
+		crc = crc<<8 ^ crcTable[byte(crc>>24)^v]
+	}
+	return crc
+}
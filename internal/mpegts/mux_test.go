@@ -0,0 +1,131 @@
+package mpegts
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestCRC32(t *testing.T) {
+	// the PAT written by ffmpeg
+	pat := []byte{0x00, 0xb0, 0x0d, 0x00, 0x01, 0xc1, 0x00, 0x00, 0x00, 0x01, 0xf0, 0x00}
+	if crc := crc32(pat); crc != 0x2ab104b2 {
+		t.Fatalf("crc %08x", crc)
+	}
+}
+
+// demux returns the payloads of the pid, the PES starts are split.
+func demux(t *testing.T, b []byte, pid uint16) [][]byte {
+	t.Helper()
+
+	if len(b)%PacketSize != 0 {
+		t.Fatalf("%d bytes", len(b))
+	}
+
+	var payloads [][]byte
+	cc := -1
+	for ; len(b) > 0; b = b[PacketSize:] {
+		p := b[:PacketSize]
+		if p[0] != 0x47 {
+			t.Fatalf("sync byte %02x", p[0])
+		}
+		if uint16(p[1]&0x1f)<<8|uint16(p[2]) != pid {
+			continue
+		}
+
+		if cc >= 0 && int(p[3]&0x0f) != (cc+1)&0x0f {
+			t.Fatalf("continuity counter %d after %d", p[3]&0x0f, cc)
+		}
+		cc = int(p[3] & 0x0f)
+
+		payload := p[4:]
+		if p[3]&0x20 != 0 {
+			payload = payload[1+int(payload[0]):]
+		}
+
+		if p[1]&0x40 != 0 {
+			payloads = append(payloads, nil)
+		}
+		payloads[len(payloads)-1] = append(payloads[len(payloads)-1], payload...)
+	}
+	return payloads
+}
+
+// pcrs returns the PCR bases of the pid.
+func pcrs(b []byte, pid uint16) []int64 {
+	var bases []int64
+	for ; len(b) >= PacketSize; b = b[PacketSize:] {
+		p := b[:PacketSize]
+		if uint16(p[1]&0x1f)<<8|uint16(p[2]) != pid || p[3]&0x20 == 0 || p[4] < 7 || p[5]&0x10 == 0 {
+			continue
+		}
+		bases = append(bases, int64(p[6])<<25|int64(p[7])<<17|int64(p[8])<<9|int64(p[9])<<1|int64(p[10]>>7))
+	}
+	return bases
+}
+
+func TestMuxer(t *testing.T) {
+	var buf bytes.Buffer
+	m := NewMuxer(&buf, StreamTypeMPEG1Audio)
+
+	err := m.WriteTables()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	frames := [][]byte{
+		bytes.Repeat([]byte{1}, 417),
+		bytes.Repeat([]byte{2}, 183-14),
+		bytes.Repeat([]byte{3}, 176-14),
+		bytes.Repeat([]byte{4}, 1),
+	}
+	for i, frame := range frames {
+		err = m.WritePES(int64(i)*2351+90000, frame)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	pat := demux(t, buf.Bytes(), pidPAT)
+	if len(pat) != 1 || !bytes.HasSuffix(pat[0][:1+16], []byte{0x2a, 0xb1, 0x04, 0xb2}) {
+		t.Fatalf("pat %x", pat)
+	}
+
+	pmt := demux(t, buf.Bytes(), pidPMT)
+	if len(pmt) != 1 || pmt[0][1] != 0x02 || crc32(pmt[0][1:1+3+18]) != 0 {
+		t.Fatalf("pmt %x", pmt)
+	}
+
+	pes := demux(t, buf.Bytes(), pidAudio)
+	if len(pes) != len(frames) {
+		t.Fatalf("%d pes", len(pes))
+	}
+	for i, p := range pes {
+		if !bytes.HasPrefix(p, []byte{0, 0, 1, streamIDAudio}) {
+			t.Fatalf("pes %d start code %x", i, p[:4])
+		}
+
+		ts := p[9:14]
+		pts := int64(ts[0]>>1&0x07)<<30 | int64(ts[1])<<22 | int64(ts[2]>>1)<<15 | int64(ts[3])<<7 | int64(ts[4]>>1)
+		if pts != int64(i)*2351+90000 {
+			t.Fatalf("pes %d pts %d", i, pts)
+		}
+
+		if !bytes.Equal(p[14:], frames[i]) {
+			t.Fatalf("pes %d payload %d bytes", i, len(p[14:]))
+		}
+	}
+
+	// the decoder gets every frame ahead of its pts
+	for i, pcr := range pcrs(buf.Bytes(), pidAudio) {
+		if pcr != int64(i)*2351+90000-pcrDelay {
+			t.Fatalf("pes %d pcr %d", i, pcr)
+		}
+	}
+	if n := len(pcrs(buf.Bytes(), pidAudio)); n != len(frames) {
+		t.Fatalf("%d pcr", n)
+	}
+
+	if err = m.WritePES(0, make([]byte, maxPESPayload+1)); err != ErrPESTooLarge {
+		t.Fatalf("large pes: %v", err)
+	}
+}
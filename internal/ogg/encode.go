@@ -198,3 +198,9 @@ func (w *Encoder) GetPageSeq() uint32 {
 func (w *Encoder) SetPageSeq(i uint32) {
 	w.page = i
 }
+
+// SetSerial switches to a new logical stream, for chaining after an EOS page.
+// The page sequence should be reset too.
+func (w *Encoder) SetSerial(id uint32) {
+	w.serial = id
+}
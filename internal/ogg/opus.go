@@ -0,0 +1,96 @@
+package ogg
+
+// OpusPacketSamples returns the samples per channel at 48kHz of the Opus packet
+// from its TOC byte, or 0 for a malformed packet.
+// See https://www.rfc-editor.org/rfc/rfc6716#section-3.1
+func OpusPacketSamples(packet []byte) int {
+	if len(packet) == 0 {
+		return 0
+	}
+
+	toc := packet[0]
+	config := int(toc >> 3)
+
+	var frameSize int
+	switch {
+	case config < 12:
+		// SILK 10, 20, 40, 60 ms
+		frameSize = [4]int{480, 960, 1920, 2880}[config%4]
+	case config < 16:
+		// Hybrid 10, 20 ms
+		frameSize = [2]int{480, 960}[config%2]
+	default:
+		// CELT 2.5, 5, 10, 20 ms
+		frameSize = [4]int{120, 240, 480, 960}[config%4]
+	}
+
+	var frames int
+	switch toc & 0b11 {
+	case 0:
+		frames = 1
+	case 1, 2:
+		frames = 2
+	case 3:
+		if len(packet) < 2 {
+			return 0
+		}
+		frames = int(packet[1] & 0x3f)
+	}
+
+	return frameSize * frames
+}
+
+// OpusPacketSilent reports whether the Opus packet is the encoder's digital silence,
+// a DTX packet without the frame data, or a CELT frame with the silence flag set.
+// The packets of several frames are not checked.
+// See https://www.rfc-editor.org/rfc/rfc6716#section-4.3
+func OpusPacketSilent(packet []byte) bool {
+	if len(packet) == 0 || packet[0]&0b11 != 0 {
+		return false
+	}
+
+	frame := packet[1:]
+	if len(frame) == 0 {
+		return true
+	}
+
+	// only the CELT-only frames start with the silence flag
+	if packet[0]>>3 < 16 {
+		return false
+	}
+	return celtSilence(frame)
+}
+
+// celtSilence decodes the first symbol of the range coder, the silence flag of probability 1/2^15,
+// as ec_dec_init and ec_dec_bit_logp of libopus.
+// See https://www.rfc-editor.org/rfc/rfc6716#section-4.1
+func celtSilence(frame []byte) bool {
+	const (
+		codeExtra = 7
+		codeBot   = 1 << 23
+		codeTop   = 1 << 31
+	)
+
+	// the bytes past the end are 0
+	read := func() uint32 {
+		if len(frame) == 0 {
+			return 0
+		}
+		b := frame[0]
+		frame = frame[1:]
+		return uint32(b)
+	}
+
+	rng := uint32(1) << codeExtra
+	rem := read()
+	val := rng - 1 - rem>>(8-codeExtra)
+	for rng <= codeBot {
+		rng <<= 8
+		sym := rem
+		rem = read()
+		sym = (sym<<8 | rem) >> (8 - codeExtra)
+		val = (val<<8 + (0xff &^ sym)) & (codeTop - 1)
+	}
+
+	return val < rng>>15
+}
@@ -0,0 +1,51 @@
+package ogg
+
+import "testing"
+
+func TestOpusPacketSamples(t *testing.T) {
+	for _, c := range []struct {
+		packet  []byte
+		samples int
+	}{
+		{nil, 0},
+		// CELT FB 20ms, the silence of cmd/issue-opus
+		{[]byte{252, 255, 254}, 960},
+		// CELT FB 20ms, 2 frames
+		{[]byte{253, 0}, 1920},
+		// CELT FB 2.5ms, code 3 with 6 frames
+		{[]byte{0x80 | 3, 6}, 720},
+		{[]byte{0x80 | 3}, 0},
+		// SILK NB 60ms
+		{[]byte{0x18}, 2880},
+		// Hybrid FB 10ms
+		{[]byte{0x70}, 480},
+	} {
+		if samples := OpusPacketSamples(c.packet); samples != c.samples {
+			t.Errorf("OpusPacketSamples(%v) = %d, want %d", c.packet, samples, c.samples)
+		}
+	}
+}
+
+func TestOpusPacketSilent(t *testing.T) {
+	for _, c := range []struct {
+		packet []byte
+		silent bool
+	}{
+		{nil, false},
+		// CELT FB 20ms, the silence of cmd/issue-opus
+		{[]byte{252, 255, 254}, true},
+		// CELT FB 20ms, short but not silent
+		{[]byte{252, 1, 2}, false},
+		{[]byte{252, 0}, false},
+		// the DTX of SILK NB 20ms
+		{[]byte{0x08}, true},
+		// SILK NB 20ms, the SILK frames have no silence flag
+		{[]byte{0x08, 255, 254}, false},
+		// CELT FB 20ms, 2 frames
+		{[]byte{253, 255, 254}, false},
+	} {
+		if silent := OpusPacketSilent(c.packet); silent != c.silent {
+			t.Errorf("OpusPacketSilent(%v) = %v, want %v", c.packet, silent, c.silent)
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package suno
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/hellodword/suno-radio/internal/ogg"
+	"github.com/teivah/broadcast"
+)
+
+// burstDuration is the audio replayed to a new listener, so the players start right away
+// and have some buffer for the network hiccups.
+const burstDuration = time.Second * 3
+
+// burstBuffer is a ring of the last pages published,
+// the MP3 listeners get the MP3 frames of the same pages.
+type burstBuffer struct {
+	mu      sync.Mutex
+	pages   []*fadePage
+	samples int64
+}
+
+// publish keeps the page and broadcasts it with its MP3 frames, under the lock so a new listener
+// gets every page once, either in the burst or live.
+func (b *burstBuffer) publish(relay *broadcast.Relay[*oggPage], mp3Relay *broadcast.Relay[*mp3Chunk], page *oggPage, samples int64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.pages = append(b.pages, &fadePage{page: page, samples: samples})
+	b.samples += samples
+	for len(b.pages) > 1 && b.samples-b.pages[0].samples >= int64(burstDuration)*DefaultSampleRate/int64(time.Second) {
+		b.samples -= b.pages[0].samples
+		b.pages[0] = nil
+		b.pages = b.pages[1:]
+	}
+
+	relay.Broadcast(page)
+	for _, chunk := range page.mp3 {
+		mp3Relay.Broadcast(chunk)
+	}
+}
+
+// reset drops the burst when the station starts again, the listener would hear the seconds before the pause first,
+// b.mu is held.
+func (b *burstBuffer) reset() {
+	clear(b.pages)
+	b.pages = b.pages[:0]
+	b.samples = 0
+}
+
+// subscribe returns the burst and the listener of the live pages after it.
+func (w *Worker) subscribe() ([]*oggPage, *broadcast.Listener[*oggPage], error) {
+	w.closeMu.Lock()
+	defer w.closeMu.Unlock()
+
+	if atomic.LoadInt32(&w.canceled) != 0 {
+		return nil, nil, ErrWorkerClosed
+	}
+
+	b := &w.burst
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	pages := make([]*oggPage, len(b.pages))
+	for i, p := range b.pages {
+		pages[i] = p.page
+	}
+
+	// the live pages wait while the burst is written
+	listener := w.broadcaster.Listener(len(pages) + 1)
+	return pages, listener, nil
+}
+
+// subscribeMP3 returns the MP3 frames of the burst and the listener of the live frames after it.
+func (w *Worker) subscribeMP3() ([]*mp3Chunk, *broadcast.Listener[*mp3Chunk], error) {
+	w.closeMu.Lock()
+	defer w.closeMu.Unlock()
+
+	if atomic.LoadInt32(&w.canceled) != 0 {
+		return nil, nil, ErrWorkerClosed
+	}
+
+	b := &w.burst
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var chunks []*mp3Chunk
+	for _, p := range b.pages {
+		chunks = append(chunks, p.page.mp3...)
+	}
+
+	listener := w.mp3Broadcaster.Listener(len(chunks) + 1)
+	return chunks, listener, nil
+}
+
+func opusSamples(packets [][]byte) int64 {
+	var samples int64
+	for _, packet := range packets {
+		samples += int64(ogg.OpusPacketSamples(packet))
+	}
+	return samples
+}
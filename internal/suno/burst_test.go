@@ -0,0 +1,119 @@
+package suno
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/hellodword/suno-radio/internal/mp3"
+)
+
+func TestStreamBurst(t *testing.T) {
+	clips := testClips(1)
+	w := newTestWorker(PlaylistOptions{})
+
+	// the station has been playing for a while for another listener
+	leave, err := w.join("other", w.Options())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer leave()
+
+	granule := int64(1000000)
+	for i := range 5 {
+		page := &oggPage{clip: clips[0], packets: testPackets(50, byte(i))}
+		granule += 50 * 960
+		page.granule = granule
+		w.burst.publish(w.broadcaster, w.mp3Broadcaster, page, 50*960)
+	}
+	if len(w.burst.pages) != 3 {
+		t.Fatalf("%d pages in the burst, want 3", len(w.burst.pages))
+	}
+
+	live := &oggPage{clip: clips[0], granule: granule + 960, packets: testPackets(1, 5)}
+	decoded := streamPages(t, w, []*oggPage{live})
+	if len(decoded) < 6 {
+		t.Fatalf("%d pages", len(decoded))
+	}
+
+	// the last 3 seconds, then the live page, from the start of the burst
+	for i, want := range []struct {
+		granule int64
+		first   byte
+	}{
+		{48000, 2},
+		{96000, 3},
+		{144000, 4},
+		{144960, 5},
+	} {
+		p := decoded[2+i]
+		if p.Granule != want.granule || p.Packets[0][1] != want.first {
+			t.Fatalf("page %d: granule %d, packet %v", i, p.Granule, p.Packets[0])
+		}
+	}
+}
+
+func TestStreamMP3Burst(t *testing.T) {
+	clips := testClips(1)
+	w := newTestWorker(PlaylistOptions{})
+
+	leave, err := w.join("other", w.Options())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer leave()
+
+	// a page of 1s
+	f := newMP3Follower(testMP3(200))
+	for i := range 5 {
+		page := &oggPage{clip: clips[0], packets: testPackets(50, byte(i))}
+		page.mp3 = []*mp3Chunk{{clip: clips[0], frames: f.until(time.Duration(i+1) * time.Second)}}
+		w.burst.publish(w.broadcaster, w.mp3Broadcaster, page, 50*960)
+	}
+
+	var buf bytes.Buffer
+	writer := newFirstWriter(&buf)
+	done := make(chan error)
+	go func() {
+		done <- w.StreamMP3("test", context.Background(), writer)
+	}()
+
+	// the burst is written once subscribed
+	<-writer.written
+	w.mp3Broadcaster.Close()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Fatal(err)
+	}
+
+	// the frames of the last 3 pages, ceil(5s / 26.122ms) - ceil(2s / 26.122ms)
+	h := mp3.Header(0xfffb9000)
+	if n := buf.Len() / h.Len(); n != 192-77 {
+		t.Fatalf("%d frames in the burst", n)
+	}
+}
+
+func TestBurstReset(t *testing.T) {
+	clips := testClips(1)
+	w := newTestWorker(PlaylistOptions{})
+
+	leave, err := w.join("test", w.Options())
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.burst.publish(w.broadcaster, w.mp3Broadcaster, &oggPage{clip: clips[0], packets: testPackets(50, 0)}, 50*960)
+
+	// the station stops, a page still published while it stops is stale too
+	leave()
+	w.burst.publish(w.broadcaster, w.mp3Broadcaster, &oggPage{clip: clips[0], packets: testPackets(1, 1)}, 960)
+
+	leave, err = w.join("test", w.Options())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer leave()
+	if len(w.burst.pages) != 0 || w.burst.samples != 0 {
+		t.Fatalf("%d pages in the burst after the station started again", len(w.burst.pages))
+	}
+}
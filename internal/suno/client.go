@@ -0,0 +1,100 @@
+package suno
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	DefaultBaseURL         = "https://studio-api.suno.ai"
+	DefaultPlaylistTimeout = time.Second * 30
+	DefaultDownloadTimeout = time.Minute * 5
+)
+
+// Client talks to the suno api, every field is optional.
+type Client struct {
+	BaseURL    string
+	HTTPClient *http.Client
+	UserAgent  string
+
+	// per request timeouts, the http.Client.Timeout is left for the caller
+	PlaylistTimeout time.Duration
+	DownloadTimeout time.Duration
+
+	// nil means DefaultRetryPolicy
+	Retry *RetryPolicy
+	// shared by every request, nil means no limit
+	Limiter *Limiter
+}
+
+// NewClient creates a Client with its own transport,
+// requests go through the proxy if it's not empty.
+func NewClient(baseURL, proxy, userAgent string) (*Client, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+
+	if proxy != "" {
+		u, err := url.Parse(proxy)
+		if err != nil {
+			return nil, err
+		}
+		transport.Proxy = http.ProxyURL(u)
+	}
+
+	return &Client{
+		BaseURL:    baseURL,
+		HTTPClient: &http.Client{Transport: transport},
+		UserAgent:  userAgent,
+	}, nil
+}
+
+func (c *Client) baseURL() string {
+	if c.BaseURL == "" {
+		return DefaultBaseURL
+	}
+	return strings.TrimSuffix(c.BaseURL, "/")
+}
+
+func (c *Client) httpClient() *http.Client {
+	if c.HTTPClient == nil {
+		return http.DefaultClient
+	}
+	return c.HTTPClient
+}
+
+func (c *Client) userAgent() string {
+	if c.UserAgent == "" {
+		return DefaultUserAgent
+	}
+	return c.UserAgent
+}
+
+func (c *Client) retry() *RetryPolicy {
+	if c.Retry == nil {
+		return DefaultRetryPolicy
+	}
+	return c.Retry
+}
+
+func (c *Client) withTimeout(ctx context.Context, timeout, fallback time.Duration) (context.Context, context.CancelFunc) {
+	if timeout == 0 {
+		timeout = fallback
+	}
+	if timeout < 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+func (c *Client) newRequest(ctx context.Context, u string) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("User-Agent", c.userAgent())
+
+	return req, nil
+}
@@ -0,0 +1,82 @@
+package suno
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestClient(t *testing.T) {
+	agents := make(chan string, 1)
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		agents <- r.UserAgent()
+		if r.URL.Path != "/api/playlist/"+testPlaylistID+"/" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write([]byte(`{"id":"` + testPlaylistID + `"}`))
+	}))
+	t.Cleanup(s.Close)
+
+	c, err := NewClient(s.URL+"/", "", "")
+	if err != nil {
+		t.Fatal("unexpected NewClient error:", err)
+	}
+	c.Retry = &RetryPolicy{MaxAttempts: 1}
+
+	_, err = c.GetPlaylist(context.Background(), testPlaylistID, 1)
+	if err != nil {
+		t.Fatal("unexpected GetPlaylist error:", err)
+	}
+	if agent := <-agents; agent != DefaultUserAgent {
+		t.Fatalf("user agent %q", agent)
+	}
+
+	c.UserAgent = "suno-radio-test"
+	_, err = c.GetPlaylist(context.Background(), testPlaylistID, 1)
+	if err != nil {
+		t.Fatal("unexpected GetPlaylist error:", err)
+	}
+	if agent := <-agents; agent != c.UserAgent {
+		t.Fatalf("user agent %q", agent)
+	}
+}
+
+func TestClientTimeout(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(time.Second * 5):
+		}
+	}))
+	t.Cleanup(s.Close)
+
+	c := &Client{BaseURL: s.URL, PlaylistTimeout: time.Millisecond * 50, Retry: &RetryPolicy{MaxAttempts: 1}}
+
+	begin := time.Now()
+	_, err := c.GetPlaylist(context.Background(), testPlaylistID, 1)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected a deadline error, got %v", err)
+	}
+	if elapsed := time.Since(begin); elapsed > time.Second {
+		t.Fatalf("the timeout took %s", elapsed)
+	}
+}
+
+func TestNewClientProxy(t *testing.T) {
+	_, err := NewClient("", "://bad", "")
+	if err == nil {
+		t.Fatal("expected NewClient error")
+	}
+
+	c, err := NewClient("", "http://127.0.0.1:7890", "")
+	if err != nil {
+		t.Fatal("unexpected NewClient error:", err)
+	}
+	if c.baseURL() != DefaultBaseURL {
+		t.Fatalf("base url %q", c.baseURL())
+	}
+}
@@ -0,0 +1,339 @@
+package suno
+
+import (
+	"context"
+	"errors"
+	"math"
+	"time"
+
+	"github.com/hellodword/suno-radio/internal/ogg"
+)
+
+const (
+	// the packets decoded before the tail, so the decoder has converged
+	fadePrerollPackets = 4
+	// the samples per channel of a re-encoded packet, 20ms
+	fadeFrameSize = 960
+	// OPUS_GET_LOOKAHEAD of the libopus encoder at 48kHz with OPUS_APPLICATION_AUDIO
+	opusLookahead = 312
+	// the max samples per channel of an Opus packet, 120ms
+	maxOpusFrameSize = 5760
+	// a bit more than what ffmpeg gives the converted clips
+	fadeBitrate = 128000
+	// the max bytes of a re-encoded packet
+	maxOpusPacketLen = 1500
+)
+
+var ErrCrossfadeUnsupported = errors.New("crossfade needs the opus build tag")
+
+// opusDecoder and opusEncoder are the libopus ones with the opus build tag,
+// the stereo samples are interleaved.
+type opusDecoder interface {
+	DecodeFloat32(data []byte, pcm []float32) (int, error)
+}
+
+type opusEncoder interface {
+	EncodeFloat32(pcm []float32, data []byte) (int, error)
+}
+
+// set in crossfade_opus.go, the default build doesn't need libopus
+var (
+	newOpusDecoder func() (opusDecoder, error)
+	newOpusEncoder func() (opusEncoder, error)
+)
+
+// CrossfadeSupported reports whether the binary is built with libopus.
+func CrossfadeSupported() bool {
+	return newOpusDecoder != nil && newOpusEncoder != nil
+}
+
+type fadePage struct {
+	page    *oggPage
+	samples int64
+}
+
+// fadeTail is the end of a clip held back until the head of the next one.
+type fadeTail struct {
+	pages   []*fadePage
+	samples int64
+	// the packets published right before the tail
+	preroll [][]byte
+}
+
+func (t *fadeTail) add(page *oggPage, samples int64) {
+	t.pages = append(t.pages, &fadePage{page: page, samples: samples})
+	t.samples += samples
+}
+
+func (t *fadeTail) packets() [][]byte {
+	var packets [][]byte
+	for _, p := range t.pages {
+		packets = append(packets, p.page.packets...)
+	}
+	return packets
+}
+
+// crossfader mixes the tail of a clip into the head of the next one, only those are decoded
+// and re-encoded, once for all the listeners.
+// It's only used by the playback goroutine.
+type crossfader struct {
+	// the fade length in samples
+	samples int64
+	// the pages of the current clip, published once the clip goes on for longer than the fade
+	tail fadeTail
+	// the tail of the previous clip waiting for the head of the current one
+	prev *fadeTail
+	head fadeTail
+	// the PreSkip of the current clip
+	preSkip int64
+}
+
+func (w *Worker) crossfader(d time.Duration) *crossfader {
+	w.fade.samples = int64(d) * DefaultSampleRate / int64(time.Second)
+	return &w.fade
+}
+
+// fadePage publishes the pages of the clip once they are out of the tail,
+// the head is mixed with the previous tail first.
+func (w *Worker) fadePage(ctx context.Context, f *crossfader, page *oggPage, pcmLen int64) {
+	if f.prev != nil {
+		if len(f.head.pages) == 0 {
+			f.preSkip = int64(page.preSkip)
+		}
+		f.head.add(page, pcmLen)
+		// the packets after the replaced ones are decoded for the lookahead of the encoder
+		if f.head.samples-f.preSkip >= f.prev.samples+opusLookahead+maxOpusFrameSize {
+			w.mixFade(ctx, f)
+		}
+		return
+	}
+
+	f.tail.add(page, pcmLen)
+	for len(f.tail.pages) > 0 && f.tail.samples-f.tail.pages[0].samples >= f.samples {
+		p := f.tail.pages[0]
+		f.tail.pages = f.tail.pages[1:]
+		f.tail.samples -= p.samples
+
+		f.tail.preroll = append(f.tail.preroll, p.page.packets...)
+		f.tail.preroll = f.tail.preroll[max(0, len(f.tail.preroll)-fadePrerollPackets):]
+
+		w.publishOpus(ctx, p.page, p.samples)
+	}
+}
+
+// endFade is called when the clip ends or is skipped, its tail waits for the next clip.
+func (w *Worker) endFade(ctx context.Context, f *crossfader) {
+	// the clip is shorter than the fade
+	if f.prev != nil && len(f.head.pages) > 0 {
+		w.mixFade(ctx, f)
+	}
+
+	if len(f.tail.pages) > 0 {
+		tail := f.tail
+		f.prev = &tail
+	}
+	f.tail = fadeTail{}
+}
+
+// flushFade publishes the tail as it is, when no clip follows.
+func (w *Worker) flushFade(ctx context.Context) {
+	f := &w.fade
+	for _, t := range []*fadeTail{f.prev, &f.head, &f.tail} {
+		if t == nil {
+			continue
+		}
+		for _, p := range t.pages {
+			w.publishOpus(ctx, p.page, p.samples)
+		}
+	}
+	w.fade = crossfader{samples: f.samples}
+}
+
+func (w *Worker) mixFade(ctx context.Context, f *crossfader) {
+	prev, head := f.prev, f.head
+	f.prev, f.head = nil, fadeTail{}
+
+	clip := head.pages[0].page.clip
+	// only the last page of a clip has the end trimming, not the one where it was skipped
+	trim := prev.pages[len(prev.pages)-1].page.trim
+
+	packets, replaced, err := crossfade(prev, trim, head.packets(), f.preSkip)
+	if err != nil {
+		w.logger.WarnContext(ctx, "crossfade", "err", err)
+		for _, p := range append(prev.pages, head.pages...) {
+			w.publishOpus(ctx, p.page, p.samples)
+		}
+		return
+	}
+
+	var pages []*oggPage
+	for len(packets) > 0 {
+		n := min(len(packets), silencePagePackets)
+		pages = append(pages, &oggPage{clip: clip, packets: packets[:n]})
+		packets = packets[n:]
+	}
+	first, last := pages[0], pages[len(pages)-1]
+	// the mixed packets start the clip in place of its head, the pre-skip is in them
+	first.preSkip = uint16(f.preSkip)
+
+	// the head goes on after the replaced packets,
+	// the MP3 can't be mixed, the frames of the tail go with the first mixed page, the replaced head with the last one
+	for _, p := range prev.pages {
+		first.mp3 = append(first.mp3, p.page.mp3...)
+	}
+	var rest []*fadePage
+	for _, p := range head.pages {
+		n := min(replaced, len(p.page.packets))
+		replaced -= n
+		if n == len(p.page.packets) {
+			last.mp3 = append(last.mp3, p.page.mp3...)
+			continue
+		}
+		if n > 0 {
+			page := &oggPage{clip: clip, packets: p.page.packets[n:], trim: p.page.trim, mp3: p.page.mp3}
+			p = &fadePage{page: page, samples: opusSamples(page.packets)}
+		}
+		rest = append(rest, p)
+	}
+
+	var mixed int64
+	for _, page := range pages {
+		mixed += opusSamples(page.packets)
+	}
+	// a clip shorter than the fade is mixed up to its end, the silence the tail fades out into is trimmed too
+	if len(rest) == 0 {
+		last.trim = head.pages[len(head.pages)-1].page.trim + mixed - head.samples
+	}
+
+	for _, page := range pages {
+		w.publishOpus(ctx, page, opusSamples(page.packets))
+	}
+	for _, p := range rest {
+		w.publishOpus(ctx, p.page, p.samples)
+	}
+}
+
+func decodeOpus(dec opusDecoder, packets [][]byte) ([]float32, error) {
+	var pcm []float32
+	buf := make([]float32, maxOpusFrameSize*DefaultChannels)
+	for _, packet := range packets {
+		n, err := dec.DecodeFloat32(packet, buf)
+		if err != nil {
+			return nil, err
+		}
+		pcm = append(pcm, buf[:n*DefaultChannels]...)
+	}
+	return pcm, nil
+}
+
+// the frame sizes the mixed samples are re-encoded with, the head packets are whole multiples of 2.5ms
+var fadeFrameSizes = []int{fadeFrameSize, 480, 240, 120}
+
+// crossfade decodes the tail of the previous clip and the head of the next one,
+// and re-encodes them mixed with an equal power fade, into 20ms packets but for the end of the last one.
+// Only the whole head packets covering the pre-skip and the tail are replaced, so the next ones go on where the mixed
+// ones end, they are decoded into the lookahead of the encoder. It returns the mixed packets and how many head
+// packets they replace, the mixed packets start with the pre-skip of the head.
+// The trim is the end trimming of the tail, the preSkip is the one of the head.
+func crossfade(prev *fadeTail, trim int64, head [][]byte, preSkip int64) ([][]byte, int, error) {
+	if !CrossfadeSupported() {
+		return nil, 0, ErrCrossfadeUnsupported
+	}
+
+	decPrev, err := newOpusDecoder()
+	if err != nil {
+		return nil, 0, err
+	}
+	decNext, err := newOpusDecoder()
+	if err != nil {
+		return nil, 0, err
+	}
+	enc, err := newOpusEncoder()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	preroll, err := decodeOpus(decPrev, prev.preroll)
+	if err != nil {
+		return nil, 0, err
+	}
+	tail, err := decodeOpus(decPrev, prev.packets())
+	if err != nil {
+		return nil, 0, err
+	}
+	tail = tail[:max(0, len(tail)-int(trim)*DefaultChannels)]
+	fade := len(tail) / DefaultChannels
+
+	replaced, samples := 0, 0
+	for replaced < len(head) && samples < int(preSkip)+fade {
+		samples += ogg.OpusPacketSamples(head[replaced])
+		replaced++
+	}
+
+	next, err := decodeOpus(decNext, head[:replaced])
+	if err != nil {
+		return nil, 0, err
+	}
+	var lookahead []float32
+	for _, packet := range head[replaced:] {
+		if len(lookahead) >= opusLookahead*DefaultChannels {
+			break
+		}
+		pcm, err := decodeOpus(decNext, [][]byte{packet})
+		if err != nil {
+			return nil, 0, err
+		}
+		lookahead = append(lookahead, pcm...)
+	}
+
+	// the encoder output lags its input by the lookahead, it's fed with the preroll first,
+	// the packets before the kept ones end on the lookahead before the fade
+	skip := (len(preroll)/DefaultChannels + opusLookahead + fadeFrameSize - 1) / fadeFrameSize
+	skip = max(1, skip)
+	pcm := make([]float32, (skip*fadeFrameSize-opusLookahead)*DefaultChannels)
+	copy(pcm[len(pcm)-len(preroll):], preroll)
+
+	// only a clip shorter than the fade ends before the tail, which fades out into silence then
+	if samples < int(preSkip)+fade {
+		samples = (int(preSkip) + fade + fadeFrameSize - 1) / fadeFrameSize * fadeFrameSize
+	}
+
+	mixed := make([]float32, (samples+opusLookahead)*DefaultChannels)
+	copy(mixed[copy(mixed, next):], lookahead)
+	for i := range fade {
+		t := float64(i) / float64(fade) * math.Pi / 2
+		out, in := float32(math.Cos(t)), float32(math.Sin(t))
+		for c := range DefaultChannels {
+			j := (int(preSkip)+i)*DefaultChannels + c
+			mixed[j] = tail[i*DefaultChannels+c]*out + mixed[j]*in
+		}
+	}
+	pcm = append(pcm, mixed...)
+
+	var packets [][]byte
+	data := make([]byte, maxOpusPacketLen)
+	encode := func(size int) ([]byte, error) {
+		n, err := enc.EncodeFloat32(pcm[:size*DefaultChannels], data)
+		if err != nil {
+			return nil, err
+		}
+		pcm = pcm[size*DefaultChannels:]
+		return append([]byte(nil), data[:n]...), nil
+	}
+	for range skip {
+		if _, err := encode(fadeFrameSize); err != nil {
+			return nil, 0, err
+		}
+	}
+	for _, size := range fadeFrameSizes {
+		for ; samples >= size; samples -= size {
+			packet, err := encode(size)
+			if err != nil {
+				return nil, 0, err
+			}
+			packets = append(packets, packet)
+		}
+	}
+
+	return packets, replaced, nil
+}
@@ -0,0 +1,29 @@
+//go:build opus
+
+package suno
+
+import (
+	"gopkg.in/hraban/opus.v2"
+)
+
+func init() {
+	newOpusDecoder = func() (opusDecoder, error) {
+		dec, err := opus.NewDecoder(DefaultSampleRate, DefaultChannels)
+		if err != nil {
+			return nil, err
+		}
+		return dec, nil
+	}
+
+	newOpusEncoder = func() (opusEncoder, error) {
+		enc, err := opus.NewEncoder(DefaultSampleRate, DefaultChannels, opus.AppAudio)
+		if err != nil {
+			return nil, err
+		}
+		err = enc.SetBitrate(fadeBitrate)
+		if err != nil {
+			return nil, err
+		}
+		return enc, nil
+	}
+}
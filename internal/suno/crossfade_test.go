@@ -0,0 +1,218 @@
+package suno
+
+import (
+	"bytes"
+	"context"
+	"math"
+	"math/bits"
+	"slices"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/hellodword/suno-radio/internal/ogg"
+)
+
+// fakeDecoder decodes a packet into its second byte for all the samples.
+type fakeDecoder struct{}
+
+func (fakeDecoder) DecodeFloat32(data []byte, pcm []float32) (int, error) {
+	n := ogg.OpusPacketSamples(data)
+	for i := range n * DefaultChannels {
+		pcm[i] = float32(data[1])
+	}
+	return n, nil
+}
+
+// fakeEncoder encodes the mean of the frame delayed by the lookahead, like libopus,
+// the TOC is the CELT fullband stereo one of the frame size.
+type fakeEncoder struct {
+	delayed []float32
+	// all the samples, if not nil
+	fed *[]float32
+}
+
+func (e *fakeEncoder) EncodeFloat32(pcm []float32, data []byte) (int, error) {
+	if e.fed != nil {
+		*e.fed = append(*e.fed, pcm...)
+	}
+	if e.delayed == nil {
+		e.delayed = make([]float32, opusLookahead*DefaultChannels)
+	}
+	e.delayed = append(e.delayed, pcm...)
+
+	var sum float64
+	for _, v := range e.delayed[:len(pcm)] {
+		sum += float64(v)
+	}
+	e.delayed = e.delayed[len(pcm):]
+
+	config := 28 + bits.TrailingZeros(uint(len(pcm)/DefaultChannels/120))
+	return copy(data, []byte{byte(config<<3 | 0x04), byte(math.Round(sum / float64(len(pcm))))}), nil
+}
+
+func fakeOpus(t *testing.T) {
+	dec, enc := newOpusDecoder, newOpusEncoder
+	t.Cleanup(func() {
+		newOpusDecoder, newOpusEncoder = dec, enc
+	})
+
+	newOpusDecoder = func() (opusDecoder, error) { return fakeDecoder{}, nil }
+	newOpusEncoder = func() (opusEncoder, error) { return &fakeEncoder{}, nil }
+}
+
+func testPackets(n int, v byte) [][]byte {
+	var packets [][]byte
+	for range n {
+		packets = append(packets, []byte{252, v, 0xff, 0xff})
+	}
+	return packets
+}
+
+// testPackets10ms are like testPackets, but of 10ms.
+func testPackets10ms(n int, v byte) [][]byte {
+	packets := testPackets(n, v)
+	for _, packet := range packets {
+		packet[0] = 30<<3 | 0x04
+	}
+	return packets
+}
+
+func TestCrossfade(t *testing.T) {
+	fakeOpus(t)
+
+	for _, c := range []struct {
+		name     string
+		head     [][]byte
+		replaced int
+		sizes    []int
+	}{
+		// 312 + 4800 samples are covered by 6 packets
+		{"20ms", append(testPackets(6, 0), testPackets(2, 200)...), 6, []int{960, 960, 960, 960, 960, 960}},
+		// and by 11 packets of 10ms, the last mixed one is of 10ms too
+		{"10ms", append(testPackets10ms(11, 0), testPackets10ms(2, 200)...), 11, []int{960, 960, 960, 960, 960, 480}},
+		// the clip ends before the tail, it fades out into silence
+		{"short", testPackets(2, 0), 2, []int{960, 960, 960, 960, 960, 960}},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			prev := &fadeTail{preroll: testPackets(4, 50)}
+			prev.add(&oggPage{packets: testPackets(5, 100)}, 5*960)
+
+			var fed []float32
+			newOpusEncoder = func() (opusEncoder, error) { return &fakeEncoder{fed: &fed}, nil }
+
+			packets, replaced, err := crossfade(prev, 0, c.head, opusLookahead)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if replaced != c.replaced {
+				t.Fatalf("%d packets replaced, want %d", replaced, c.replaced)
+			}
+			var sizes []int
+			for _, packet := range packets {
+				sizes = append(sizes, ogg.OpusPacketSamples(packet))
+			}
+			if !slices.Equal(sizes, c.sizes) {
+				t.Fatalf("mixed packets of %v samples, want %v", sizes, c.sizes)
+			}
+
+			// the first packet is the pre-skip of the head and the start of the fade, 312 samples of 0 and 648 of about 100,
+			// not the preroll delayed by the lookahead
+			if v := packets[0][1]; v != 67 {
+				t.Fatalf("first packet %d", v)
+			}
+			for i := 2; i < len(packets); i++ {
+				if packets[i][1] >= packets[i-1][1] {
+					t.Fatalf("packet %d: %d after %d", i, packets[i][1], packets[i-1][1])
+				}
+			}
+			if v := packets[len(packets)-1][1]; v > 20 {
+				t.Fatalf("last packet %d", v)
+			}
+
+			// the lookahead is the continuation of the head, if any
+			want := float32(200)
+			if replaced == len(c.head) {
+				want = 0
+			}
+			for _, v := range fed[len(fed)-opusLookahead*DefaultChannels:] {
+				if v != want {
+					t.Fatalf("lookahead %v, want %v", v, want)
+				}
+			}
+		})
+	}
+}
+
+func TestStreamCrossfade(t *testing.T) {
+	fakeOpus(t)
+
+	clips := testClips(2)
+	w := newTestWorker(PlaylistOptions{Crossfade: time.Millisecond * 100})
+	atomic.StoreInt32(&w.streamCount, 1)
+
+	listener := w.broadcaster.Listener(64)
+	defer listener.Close()
+
+	for i, v := range []byte{200, 0} {
+		var buf bytes.Buffer
+		e := ogg.NewEncoder(DefaultOggSerial, &buf)
+		// the pre-skip of the second clip isn't on the 20ms grid of the mixed packets
+		idh := &ogg.IDHeader{Version: 1, OutputChannelCount: DefaultChannels, InputSampleRate: DefaultSampleRate, PreSkip: uint16(i) * opusLookahead}
+		packets, err := idh.Encode()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = e.EncodeBOS(0, packets); err != nil {
+			t.Fatal(err)
+		}
+		for page := range 3 {
+			granule := int64(page+1) * 5 * 960
+			if page < 2 {
+				err = e.Encode(granule, testPackets(5, v))
+			} else {
+				err = e.EncodeEOS(granule, testPackets(5, v))
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		_, err = w.streamOgg(context.Background(), clips[i], &buf, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	w.flushFade(context.Background())
+
+	var values []byte
+	var granule int64
+	var preSkips []uint16
+	for len(listener.Ch()) > 0 {
+		page := <-listener.Ch()
+		if page.preSkip != 0 {
+			preSkips = append(preSkips, page.preSkip)
+		}
+		for _, packet := range page.packets {
+			values = append(values, packet[1])
+		}
+		granule = page.granule
+	}
+
+	// the tail of the first clip and the head of the second one are mixed into the 6 packets covering the pre-skip
+	// and the tail, the second clip goes on with its 7th packet, none of its samples is dropped or repeated
+	if len(values) != 25 || granule != 25*960 {
+		t.Fatalf("%d packets, granule %d", len(values), granule)
+	}
+	if !slices.Equal(preSkips, []uint16{opusLookahead}) {
+		t.Fatalf("pre-skips %v", preSkips)
+	}
+	for i, v := range values {
+		switch {
+		case i < 10 && v != 200,
+			i >= 10 && i < 15 && (v == 200 || v == 0),
+			i >= 16 && v != 0:
+			t.Fatalf("packet %d: %d in %v", i, v, values)
+		}
+	}
+}
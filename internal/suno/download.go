@@ -0,0 +1,346 @@
+package suno
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// MP3Meta is stored next to each mp3 as <clip>.mp3.json,
+// it's written before the body so the .tmp can be resumed with If-Range.
+type MP3Meta struct {
+	URL string `json:"url"`
+	// only for the If-Range of a resumed .tmp, a full fetch is unconditional
+	// since it replaces a missing or corrupted mp3, a 304 would leave nothing to play
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"last_modified,omitempty"`
+	Size         int64  `json:"size,omitempty"`
+	SHA256       string `json:"sha256,omitempty"`
+	// the mtime in ns when the checksum was verified, CheckMP3 trusts it along with the size
+	ModTime int64 `json:"mod_time,omitempty"`
+}
+
+var ErrChecksumMismatch = errors.New("checksum mismatch")
+
+func mp3MetaPath(path string) string {
+	return path + ".json"
+}
+
+func readMP3Meta(path string) (*MP3Meta, error) {
+	b, err := os.ReadFile(mp3MetaPath(path))
+	if err != nil {
+		return nil, err
+	}
+
+	var meta MP3Meta
+	err = json.Unmarshal(b, &meta)
+	if err != nil {
+		return nil, err
+	}
+
+	return &meta, nil
+}
+
+func writeMP3Meta(path string, meta *MP3Meta) error {
+	b, err := json.Marshal(meta)
+	if err != nil {
+		return err
+	}
+
+	tmp := mp3MetaPath(path) + ".tmp"
+	err = os.WriteFile(tmp, b, 0644)
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, mp3MetaPath(path))
+}
+
+func fileSHA256(path string) (string, int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", 0, err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	n, err := io.Copy(h, f)
+	if err != nil {
+		return "", 0, err
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), n, nil
+}
+
+// VerifyMP3 checks the mp3 against the checksum in its sidecar,
+// mp3s downloaded before the sidecar existed get one on the first call.
+// The size and the mtime are recorded for CheckMP3.
+func VerifyMP3(path string) error {
+	stat, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+
+	sum, size, err := fileSHA256(path)
+	if err != nil {
+		return err
+	}
+
+	meta, err := readMP3Meta(path)
+	if errors.Is(err, os.ErrNotExist) {
+		meta, err = &MP3Meta{}, nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if meta.SHA256 != "" && (meta.SHA256 != sum || meta.Size != size) {
+		return fmt.Errorf("%w %s", ErrChecksumMismatch, path)
+	}
+
+	if meta.SHA256 == sum && meta.ModTime == stat.ModTime().UnixNano() {
+		return nil
+	}
+
+	meta.Size = size
+	meta.SHA256 = sum
+	meta.ModTime = stat.ModTime().UnixNano()
+	return writeMP3Meta(path, meta)
+}
+
+// CheckMP3 trusts the mp3 if its size and mtime are the ones verified before,
+// otherwise it's hashed again with VerifyMP3.
+func CheckMP3(path string) error {
+	stat, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if stat.IsDir() {
+		return fmt.Errorf("%s is a directory", path)
+	}
+
+	meta, err := readMP3Meta(path)
+	if err == nil && meta.SHA256 != "" && meta.Size == stat.Size() && meta.ModTime == stat.ModTime().UnixNano() {
+		return nil
+	}
+
+	return VerifyMP3(path)
+}
+
+// RemoveMP3 removes the mp3 and everything downloaded along with it.
+func RemoveMP3(path string) {
+	os.Remove(path)
+	os.Remove(path + ".tmp")
+	os.Remove(mp3MetaPath(path))
+}
+
+func isAudioContentType(contentType string) bool {
+	if contentType == "" {
+		return true
+	}
+
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+
+	return strings.HasPrefix(mediaType, "audio/") ||
+		mediaType == "application/octet-stream" ||
+		mediaType == "binary/octet-stream"
+}
+
+// DownloadMP3 downloads u to path, a verified mp3 is never re-fetched,
+// a leftover .tmp is resumed with a Range request if its version can be validated.
+func (c *Client) DownloadMP3(ctx context.Context, u, path string) error {
+	if _, err := os.Stat(path); err == nil {
+		err = CheckMP3(path)
+		if err == nil {
+			return nil
+		}
+		RemoveMP3(path)
+	}
+
+	return c.retry().Do(ctx, func() error {
+		return c.downloadMP3(ctx, u, path)
+	})
+}
+
+func (c *Client) downloadMP3(ctx context.Context, u, path string) error {
+	err := c.Limiter.Wait(ctx)
+	if err != nil {
+		return err
+	}
+
+	tmpPath := path + ".tmp"
+
+	// only the same version is resumed, a changed file would get its tail appended to the old head
+	var offset int64
+	meta, _ := readMP3Meta(path)
+	if stat, err := os.Stat(tmpPath); err == nil && meta != nil && meta.URL == u && meta.validator() != "" {
+		offset = stat.Size()
+	} else {
+		os.Remove(tmpPath)
+	}
+
+	ctx, cancel := c.withTimeout(ctx, c.DownloadTimeout, DefaultDownloadTimeout)
+	defer cancel()
+
+	res, err := c.getMP3(ctx, u, offset, meta)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode == http.StatusRequestedRangeNotSatisfiable && offset > 0 {
+		// the .tmp is broken or complete but unknown, start over once
+		res.Body.Close()
+		os.Remove(tmpPath)
+		offset = 0
+
+		res, err = c.getMP3(ctx, u, 0, nil)
+		if err != nil {
+			return err
+		}
+		defer res.Body.Close()
+	}
+
+	if !(http.StatusOK <= res.StatusCode && res.StatusCode < http.StatusMultipleChoices) {
+		err = newStatusError(res)
+		return err
+	}
+
+	contentType := res.Header.Get("content-type")
+	if !isAudioContentType(contentType) {
+		err = fmt.Errorf("content-type %s", contentType)
+		return err
+	}
+
+	flag := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	if res.StatusCode == http.StatusPartialContent {
+		start, err := parseContentRangeStart(res.Header.Get("content-range"))
+		if err != nil {
+			return err
+		}
+
+		if start != offset {
+			err = fmt.Errorf("content-range start %d offset %d", start, offset)
+			return err
+		}
+
+		flag = os.O_WRONLY | os.O_APPEND
+	} else {
+		offset = 0
+		meta = &MP3Meta{
+			URL:          u,
+			ETag:         res.Header.Get("etag"),
+			LastModified: res.Header.Get("last-modified"),
+		}
+
+		err = writeMP3Meta(path, meta)
+		if err != nil {
+			return err
+		}
+	}
+
+	// chunked responses come without content-length
+	var contentLength int64 = -1
+	if contentLengthValue := res.Header.Get("content-length"); contentLengthValue != "" {
+		contentLength, err = strconv.ParseInt(contentLengthValue, 10, 0)
+		if err != nil {
+			return err
+		}
+	}
+
+	f, err := os.OpenFile(tmpPath, flag, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	// partial progress stays in the .tmp for the next attempt
+	written, err := io.Copy(f, res.Body)
+	if err != nil {
+		return err
+	}
+
+	if contentLength >= 0 && written != contentLength {
+		err = fmt.Errorf("content-length %d written %d: %w", contentLength, written, io.ErrUnexpectedEOF)
+		return err
+	}
+
+	if offset+written <= 0 {
+		err = fmt.Errorf("empty body")
+		return err
+	}
+
+	err = f.Close()
+	if err != nil {
+		return err
+	}
+
+	// verified once here, CheckMP3 trusts the size and the mtime afterwards
+	meta.SHA256, meta.Size, err = fileSHA256(tmpPath)
+	if err != nil {
+		return err
+	}
+
+	stat, err := os.Stat(tmpPath)
+	if err != nil {
+		return err
+	}
+	meta.ModTime = stat.ModTime().UnixNano()
+
+	err = writeMP3Meta(path, meta)
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(tmpPath, path)
+}
+
+// validator is the If-Range value of the version the meta was written for.
+func (meta *MP3Meta) validator() string {
+	if meta.ETag != "" {
+		return meta.ETag
+	}
+	return meta.LastModified
+}
+
+// getMP3 requests the mp3 from the offset if it's > 0, the meta validates the version.
+func (c *Client) getMP3(ctx context.Context, u string, offset int64, meta *MP3Meta) (*http.Response, error) {
+	req, err := c.newRequest(ctx, u)
+	if err != nil {
+		return nil, err
+	}
+
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+		req.Header.Set("If-Range", meta.validator())
+	}
+
+	return c.httpClient().Do(req)
+}
+
+// parseContentRangeStart parses "bytes 100-199/200".
+func parseContentRangeStart(v string) (int64, error) {
+	rest, ok := strings.CutPrefix(v, "bytes ")
+	if !ok {
+		return 0, fmt.Errorf("content-range %s", v)
+	}
+
+	start, _, ok := strings.Cut(rest, "-")
+	if !ok {
+		return 0, fmt.Errorf("content-range %s", v)
+	}
+
+	return strconv.ParseInt(start, 10, 64)
+}
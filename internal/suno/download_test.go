@@ -0,0 +1,185 @@
+package suno
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestDownloadMP3Resume(t *testing.T) {
+	content := bytes.Repeat([]byte("suno-radio"), 1000)
+
+	var requests, ranged int32
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		if r.Header.Get("Range") != "" {
+			atomic.AddInt32(&ranged, 1)
+		}
+		w.Header().Set("Content-Type", "audio/mpeg")
+		w.Header().Set("ETag", `"v1"`)
+		http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(content))
+	}))
+	defer s.Close()
+
+	c := newTestClient(s.URL)
+	p := filepath.Join(t.TempDir(), "clip.mp3")
+	u := s.URL + "/clip.mp3"
+
+	// an interrupted download
+	err := writeMP3Meta(p, &MP3Meta{URL: u, ETag: `"v1"`})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.WriteFile(p+".tmp", content[:4000], 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = c.DownloadMP3(context.Background(), u, p)
+	if err != nil {
+		t.Fatal("unexpected DownloadMP3 error:", err)
+	}
+
+	b, err := os.ReadFile(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(b, content) {
+		t.Fatalf("got %d bytes, expected %d", len(b), len(content))
+	}
+
+	if atomic.LoadInt32(&ranged) != 1 {
+		t.Fatalf("got %d range requests, expected 1", ranged)
+	}
+
+	if err = VerifyMP3(p); err != nil {
+		t.Fatal("unexpected VerifyMP3 error:", err)
+	}
+
+	// verified, no more requests
+	err = c.DownloadMP3(context.Background(), u, p)
+	if err != nil {
+		t.Fatal("unexpected DownloadMP3 error:", err)
+	}
+	if atomic.LoadInt32(&requests) != 1 {
+		t.Fatalf("got %d requests, expected 1", requests)
+	}
+
+	// corrupted, fetched again
+	err = os.WriteFile(p, content[:10], 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = VerifyMP3(p); err == nil {
+		t.Fatal("expected VerifyMP3 error")
+	}
+	err = c.DownloadMP3(context.Background(), u, p)
+	if err != nil {
+		t.Fatal("unexpected DownloadMP3 error:", err)
+	}
+	if atomic.LoadInt32(&requests) != 2 || VerifyMP3(p) != nil {
+		t.Fatalf("got %d requests, expected 2", requests)
+	}
+}
+
+func TestDownloadMP3Restart(t *testing.T) {
+	content := bytes.Repeat([]byte("suno-radio"), 1000)
+
+	for _, tc := range []struct {
+		name     string
+		meta     *MP3Meta
+		tmp      []byte
+		requests int32
+		ranged   int32
+	}{
+		// a changed file can't be told apart without a validator
+		{"no validator", &MP3Meta{}, content[:4000], 1, 0},
+		// the .tmp is longer than the file, truncated and fetched once more
+		{"not satisfiable", &MP3Meta{ETag: `"v1"`}, append(content, content[:10]...), 2, 1},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var requests, ranged int32
+			s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				atomic.AddInt32(&requests, 1)
+				if r.Header.Get("Range") != "" {
+					atomic.AddInt32(&ranged, 1)
+				}
+				w.Header().Set("Content-Type", "audio/mpeg")
+				w.Header().Set("ETag", `"v1"`)
+				http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(content))
+			}))
+			defer s.Close()
+
+			c := newTestClient(s.URL)
+			p := filepath.Join(t.TempDir(), "clip.mp3")
+			u := s.URL + "/clip.mp3"
+
+			tc.meta.URL = u
+			err := writeMP3Meta(p, tc.meta)
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = os.WriteFile(p+".tmp", tc.tmp, 0644)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = c.DownloadMP3(context.Background(), u, p)
+			if err != nil {
+				t.Fatal("unexpected DownloadMP3 error:", err)
+			}
+
+			b, err := os.ReadFile(p)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(b, content) {
+				t.Fatalf("got %d bytes, expected %d", len(b), len(content))
+			}
+			if atomic.LoadInt32(&requests) != tc.requests || atomic.LoadInt32(&ranged) != tc.ranged {
+				t.Fatalf("got %d requests and %d range requests, expected %d and %d", requests, ranged, tc.requests, tc.ranged)
+			}
+		})
+	}
+}
+
+func TestCheckMP3(t *testing.T) {
+	p := filepath.Join(t.TempDir(), "clip.mp3")
+	err := os.WriteFile(p, []byte("suno-radio"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// hashed once, the sidecar is written
+	if err = CheckMP3(p); err != nil {
+		t.Fatal("unexpected CheckMP3 error:", err)
+	}
+
+	// the size and the mtime match, the checksum isn't read again
+	meta, err := readMP3Meta(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	meta.SHA256 = "stale"
+	if err = writeMP3Meta(p, meta); err != nil {
+		t.Fatal(err)
+	}
+	if err = CheckMP3(p); err != nil {
+		t.Fatal("unexpected CheckMP3 error:", err)
+	}
+
+	// touched, hashed again
+	mtime := time.Now().Add(time.Hour)
+	if err = os.Chtimes(p, mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+	if err = CheckMP3(p); err == nil {
+		t.Fatal("expected CheckMP3 error")
+	}
+}
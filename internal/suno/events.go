@@ -0,0 +1,59 @@
+package suno
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/teivah/broadcast"
+)
+
+const (
+	EventTrackChange     = "track-change"
+	EventListenerCount   = "listener-count"
+	EventPlaylistRefresh = "playlist-refresh"
+)
+
+// Event is pushed to the frontends when the Worker changes.
+type Event struct {
+	Type string    `json:"type"`
+	Time time.Time `json:"time"`
+	Data any       `json:"data"`
+}
+
+func newEvent(typ string, data any) *Event {
+	return &Event{Type: typ, Time: time.Now(), Data: data}
+}
+
+// eventsCapacity is how many events a slow subscriber may lag behind before missing some.
+const eventsCapacity = 16
+
+func (w *Worker) publish(typ string, data any) {
+	w.events.Broadcast(newEvent(typ, data))
+}
+
+func (w *Worker) publishListenerCount() {
+	w.publish(EventListenerCount, map[string]any{
+		"listener": atomic.LoadInt32(&w.streamCount),
+	})
+}
+
+// Subscribe returns the current state as the first events followed by the changes,
+// the channel is closed when the Worker is closed, the listener must be closed by the caller.
+func (w *Worker) Subscribe() ([]*Event, *broadcast.Listener[*Event], error) {
+	listener, err := listen(w, w.events, eventsCapacity)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	current := []*Event{
+		newEvent(EventListenerCount, map[string]any{
+			"listener": atomic.LoadInt32(&w.streamCount),
+		}),
+	}
+
+	if clip, ok := w.listeningCLipID.Load().(*PlaylistClip); ok && atomic.LoadInt32(&w.playing) != 0 {
+		current = append(current, newEvent(EventTrackChange, clip.Info()))
+	}
+
+	return current, listener, nil
+}
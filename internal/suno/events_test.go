@@ -0,0 +1,45 @@
+package suno
+
+import (
+	"log/slog"
+	"testing"
+
+	"github.com/teivah/broadcast"
+)
+
+func TestSubscribe(t *testing.T) {
+	w := &Worker{logger: slog.Default(), events: broadcast.NewRelay[*Event]()}
+
+	clip := testClips(1)[0]
+	w.listeningCLipID.Store(clip)
+
+	current, listener, err := w.Subscribe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	if len(current) != 1 || current[0].Type != EventListenerCount {
+		t.Fatalf("current events while not playing %+v", current)
+	}
+
+	w.publish(EventTrackChange, clip.Info())
+	event := <-listener.Ch()
+	if event.Type != EventTrackChange || event.Data.(map[string]any)["id"] != clip.Clip.ID {
+		t.Fatalf("event %+v", event)
+	}
+
+	w.playing = 1
+	current, playing, err := w.Subscribe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer playing.Close()
+	if len(current) != 2 || current[1].Type != EventTrackChange {
+		t.Fatalf("current events while playing %+v", current)
+	}
+
+	w.events.Close()
+	if _, ok := <-listener.Ch(); ok {
+		t.Fatal("listener not closed with the worker")
+	}
+}
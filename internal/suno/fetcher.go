@@ -4,114 +4,108 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"io"
 	"net/http"
-	"os"
-	"strconv"
 )
 
 var (
 	DefaultUserAgent = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/116.0.0.0 Safari/537.36"
 )
 
-func GetPlaylist(ctx context.Context, id string, page uint) (*Playlist, error) {
+func (c *Client) GetPlaylist(ctx context.Context, id string, page uint) (*Playlist, error) {
 	if page == 0 {
 		page = 1
 	}
-	u := fmt.Sprintf("https://studio-api.suno.ai/api/playlist/%s/?page=%d", id, page)
 
-	client := &http.Client{
-		Transport: http.DefaultTransport,
-	}
-
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	var p *Playlist
+	err := c.retry().Do(ctx, func() error {
+		var err error
+		p, err = c.getPlaylist(ctx, id, page)
+		return err
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	req.Header.Set("User-Agent", DefaultUserAgent)
+	return p, nil
+}
 
-	res, err := client.Do(req)
+func (c *Client) getPlaylist(ctx context.Context, id string, page uint) (*Playlist, error) {
+	u := fmt.Sprintf("%s/api/playlist/%s/?page=%d", c.baseURL(), id, page)
+
+	err := c.Limiter.Wait(ctx)
 	if err != nil {
 		return nil, err
 	}
 
-	defer res.Body.Close()
+	ctx, cancel := c.withTimeout(ctx, c.PlaylistTimeout, DefaultPlaylistTimeout)
+	defer cancel()
 
-	var p Playlist
-	err = json.NewDecoder(res.Body).Decode(&p)
+	req, err := c.newRequest(ctx, u)
 	if err != nil {
 		return nil, err
 	}
 
-	if p.ID != id {
-		err = fmt.Errorf("%s != %s", p.ID, id)
-		return nil, err
-	}
-
-	return &p, nil
-}
-
-func DownloadMP3(ctx context.Context, u, path string) error {
-
-	tmpPath := path + ".tmp"
-	os.Remove(tmpPath)
-	os.Remove(path)
-
-	c := &http.Client{
-		Transport: http.DefaultTransport,
-	}
-
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	res, err := c.httpClient().Do(req)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	req.Header.Set("User-Agent", DefaultUserAgent)
-
-	res, err := c.Do(req)
-	if err != nil {
-		return err
-	}
 	defer res.Body.Close()
 
 	if !(http.StatusOK <= res.StatusCode && res.StatusCode < http.StatusMultipleChoices) {
-		err = fmt.Errorf("status code %d", res.StatusCode)
-		return err
-	}
-
-	contentType := res.Header.Get("content-type")
-	if contentType != "audio/mp3" {
-		err = fmt.Errorf("content-type %s", contentType)
-		return err
-	}
-
-	contentLengthValue := res.Header.Get("content-length")
-	contentLength, err := strconv.ParseInt(contentLengthValue, 10, 0)
-	if err != nil {
-		return err
-	}
-
-	if contentLength <= 0 {
-		err = fmt.Errorf("content-length %d", contentLength)
-		return err
+		err = newStatusError(res)
+		return nil, err
 	}
 
-	f, err := os.OpenFile(tmpPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	var p Playlist
+	err = json.NewDecoder(res.Body).Decode(&p)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	defer f.Close()
 
-	written, err := io.Copy(f, res.Body)
-	if err != nil {
-		return err
+	if p.ID != id {
+		err = fmt.Errorf("%s != %s", p.ID, id)
+		return nil, err
 	}
 
-	if written != contentLength {
-		err = fmt.Errorf("content-length %d written %d", contentLength, written)
-		return err
-	}
+	return &p, nil
+}
 
-	return os.Rename(tmpPath, path)
+// GetPlaylistAll walks every page of the playlist and merges the clips,
+// stops after maxClips clips if maxClips > 0.
+func (c *Client) GetPlaylistAll(ctx context.Context, id string, maxClips int) (*Playlist, error) {
+	var all *Playlist
+	seen := make(map[string]struct{})
+
+	for page := uint(1); ; page++ {
+		p, err := c.GetPlaylist(ctx, id, page)
+		if err != nil {
+			return nil, err
+		}
+
+		if all == nil {
+			all = &Playlist{PlaylistInfo: p.PlaylistInfo, CurrentPage: p.CurrentPage}
+		}
+
+		added := 0
+		for _, clip := range p.PlaylistClips {
+			if _, ok := seen[clip.Clip.ID]; ok {
+				continue
+			}
+			seen[clip.Clip.ID] = struct{}{}
+			all.PlaylistClips = append(all.PlaylistClips, clip)
+			added++
+
+			if maxClips > 0 && len(all.PlaylistClips) >= maxClips {
+				return all, nil
+			}
+		}
+
+		// an empty or repeated page means the end, some playlists report no total
+		if added == 0 || (p.NumTotalResults > 0 && len(all.PlaylistClips) >= p.NumTotalResults) {
+			break
+		}
+	}
+
+	return all, nil
 }
@@ -0,0 +1,227 @@
+package suno
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+)
+
+const testPlaylistID = "1190bf92-10dc-4ce5-968a-7a377f37f984"
+
+func newTestPlaylistServer(t *testing.T, total, pageSize int, failures int32) (*httptest.Server, *int32) {
+	return newTestPlaylistServerReporting(t, total, total, pageSize, failures)
+}
+
+// newTestPlaylistServerReporting serves the total clips, but reports the reported total.
+func newTestPlaylistServerReporting(t *testing.T, total, reported, pageSize int, failures int32) (*httptest.Server, *int32) {
+	var requests int32
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&requests, 1) <= failures {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+
+		if r.URL.Path != "/api/playlist/"+testPlaylistID+"/" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+
+		var p Playlist
+		p.ID = testPlaylistID
+		p.NumTotalResults = reported
+		p.CurrentPage = page
+		for i := (page - 1) * pageSize; i < page*pageSize && i < total; i++ {
+			clip := &PlaylistClip{RelativeIndex: float64(i)}
+			clip.Clip.ID = fmt.Sprintf("clip-%d", i)
+			p.PlaylistClips = append(p.PlaylistClips, clip)
+		}
+
+		_ = json.NewEncoder(w).Encode(&p)
+	}))
+	t.Cleanup(s.Close)
+	return s, &requests
+}
+
+func newTestClient(baseURL string) *Client {
+	return &Client{
+		BaseURL: baseURL,
+		Retry:   &RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond * 10},
+	}
+}
+
+func TestGetPlaylistAll(t *testing.T) {
+	s, _ := newTestPlaylistServer(t, 45, 20, 0)
+	c := newTestClient(s.URL)
+
+	p, err := c.GetPlaylistAll(context.Background(), testPlaylistID, 0)
+	if err != nil {
+		t.Fatal("unexpected GetPlaylistAll error:", err)
+	}
+
+	if len(p.PlaylistClips) != 45 || p.NumTotalResults != 45 {
+		t.Fatalf("got %d clips, total %d", len(p.PlaylistClips), p.NumTotalResults)
+	}
+
+	p, err = c.GetPlaylistAll(context.Background(), testPlaylistID, 30)
+	if err != nil {
+		t.Fatal("unexpected GetPlaylistAll error:", err)
+	}
+
+	if len(p.PlaylistClips) != 30 {
+		t.Fatalf("got %d clips, expected 30", len(p.PlaylistClips))
+	}
+}
+
+func TestGetPlaylistAllNoTotal(t *testing.T) {
+	s, requests := newTestPlaylistServerReporting(t, 45, 0, 20, 0)
+	c := newTestClient(s.URL)
+
+	p, err := c.GetPlaylistAll(context.Background(), testPlaylistID, 0)
+	if err != nil {
+		t.Fatal("unexpected GetPlaylistAll error:", err)
+	}
+
+	// the pages go on until the empty one
+	if len(p.PlaylistClips) != 45 || atomic.LoadInt32(requests) != 4 {
+		t.Fatalf("got %d clips after %d requests", len(p.PlaylistClips), atomic.LoadInt32(requests))
+	}
+}
+
+func TestGetPlaylistRetry(t *testing.T) {
+	s, requests := newTestPlaylistServer(t, 5, 20, 2)
+	c := newTestClient(s.URL)
+
+	p, err := c.GetPlaylist(context.Background(), testPlaylistID, 1)
+	if err != nil {
+		t.Fatal("unexpected GetPlaylist error:", err)
+	}
+
+	if len(p.PlaylistClips) != 5 || atomic.LoadInt32(requests) != 3 {
+		t.Fatalf("got %d clips after %d requests", len(p.PlaylistClips), atomic.LoadInt32(requests))
+	}
+
+	s, requests = newTestPlaylistServer(t, 5, 20, 10)
+	c = newTestClient(s.URL)
+
+	_, err = c.GetPlaylist(context.Background(), testPlaylistID, 1)
+	if err == nil {
+		t.Fatal("expected GetPlaylist error")
+	}
+
+	if atomic.LoadInt32(requests) != 3 {
+		t.Fatalf("got %d requests, expected 3", atomic.LoadInt32(requests))
+	}
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(r *http.Request) (*http.Response, error) { return f(r) }
+
+func TestRetryTransportError(t *testing.T) {
+	for _, c := range []struct {
+		name     string
+		err      error
+		requests int32
+	}{
+		{"reset", fmt.Errorf("read: %w", syscall.ECONNRESET), 3},
+		{"unexpected eof", io.ErrUnexpectedEOF, 3},
+		{"certificate", &tls.CertificateVerificationError{Err: errors.New("unknown authority")}, 1},
+		{"permanent", errors.New("permanent"), 1},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			var requests int32
+			client := newTestClient("http://suno.invalid")
+			client.HTTPClient = &http.Client{Transport: roundTripperFunc(func(*http.Request) (*http.Response, error) {
+				atomic.AddInt32(&requests, 1)
+				return nil, c.err
+			})}
+
+			_, err := client.GetPlaylist(context.Background(), testPlaylistID, 1)
+			if !errors.Is(err, c.err) {
+				t.Fatalf("got %v, expected %v", err, c.err)
+			}
+			if atomic.LoadInt32(&requests) != c.requests {
+				t.Fatalf("got %d requests, expected %d", atomic.LoadInt32(&requests), c.requests)
+			}
+		})
+	}
+
+	// the unsupported scheme never reaches the transport
+	client := newTestClient("ftp://suno.invalid")
+	if _, err := client.GetPlaylist(context.Background(), testPlaylistID, 1); err == nil || retryable(err) {
+		t.Fatalf("got %v, expected a permanent error", err)
+	}
+}
+
+func TestLimiter(t *testing.T) {
+	l := NewLimiter(100, 2)
+
+	begin := time.Now()
+	for range 6 {
+		if err := l.Wait(context.Background()); err != nil {
+			t.Fatal("unexpected Wait error:", err)
+		}
+	}
+
+	// 2 burst, 4 waited at 10ms each
+	if elapsed := time.Since(begin); elapsed < time.Millisecond*35 {
+		t.Fatalf("limiter too fast: %s", elapsed)
+	}
+}
+
+func TestRetryAfterCapped(t *testing.T) {
+	var requests int32
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.Header().Set("Retry-After", "86400")
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	t.Cleanup(s.Close)
+	c := newTestClient(s.URL)
+
+	begin := time.Now()
+	_, err := c.GetPlaylist(context.Background(), testPlaylistID, 1)
+	if err == nil {
+		t.Fatal("expected GetPlaylist error")
+	}
+	if elapsed := time.Since(begin); elapsed > time.Second || atomic.LoadInt32(&requests) != 3 {
+		t.Fatalf("%d requests in %s", atomic.LoadInt32(&requests), elapsed)
+	}
+}
+
+func TestLimiterCanceled(t *testing.T) {
+	l := NewLimiter(10, 1)
+	if err := l.Wait(context.Background()); err != nil {
+		t.Fatal("unexpected Wait error:", err)
+	}
+
+	// the canceled callers give their tokens back
+	for range 5 {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+		if err := l.Wait(ctx); err == nil {
+			t.Fatal("expected Wait error")
+		}
+		cancel()
+	}
+
+	begin := time.Now()
+	if err := l.Wait(context.Background()); err != nil {
+		t.Fatal("unexpected Wait error:", err)
+	}
+	if elapsed := time.Since(begin); elapsed > time.Millisecond*300 {
+		t.Fatalf("waited %s for a token", elapsed)
+	}
+}
@@ -0,0 +1,177 @@
+package suno
+
+import (
+	"encoding/json"
+	"errors"
+	"maps"
+	"os"
+	"path"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	historyFile = "history.json"
+	historyLen  = 200
+)
+
+// HistoryEntry is a clip played by the Worker.
+type HistoryEntry struct {
+	ClipID    string        `json:"clip_id"`
+	Title     string        `json:"title,omitempty"`
+	StartedAt time.Time     `json:"started_at"`
+	Duration  time.Duration `json:"duration"`
+	// at the start of the clip
+	Listeners int32 `json:"listeners"`
+	Skipped   bool  `json:"skipped,omitempty"`
+}
+
+// PlayCount is how many times a clip was played since the playlist was added.
+type PlayCount struct {
+	ClipID string `json:"clip_id"`
+	Title  string `json:"title,omitempty"`
+	Count  int64  `json:"count"`
+}
+
+// history keeps the last historyLen entries and the cumulative play counts,
+// both are persisted in the playlist dir.
+type history struct {
+	mu      sync.Mutex
+	p       string
+	Entries []HistoryEntry   `json:"entries"`
+	Plays   map[string]int64 `json:"plays"`
+}
+
+func newHistory(dir string) *history {
+	return &history{p: path.Join(dir, historyFile), Plays: make(map[string]int64)}
+}
+
+func (h *history) load() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	b, err := os.ReadFile(h.p)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	err = json.Unmarshal(b, h)
+	if err != nil {
+		return err
+	}
+
+	if h.Plays == nil {
+		h.Plays = make(map[string]int64)
+	}
+	if len(h.Entries) > historyLen {
+		h.Entries = h.Entries[len(h.Entries)-historyLen:]
+	}
+
+	return nil
+}
+
+func (h *history) save() error {
+	b, err := json.Marshal(h)
+	if err != nil {
+		return err
+	}
+
+	err = os.WriteFile(h.p+".tmp", b, 0644)
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(h.p+".tmp", h.p)
+}
+
+// start records the clip and counts the play.
+func (h *history) start(clip *PlaylistClip, listeners int32) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.Entries = append(h.Entries, HistoryEntry{
+		ClipID:    clip.Clip.ID,
+		Title:     clip.Clip.Title,
+		StartedAt: time.Now(),
+		Listeners: listeners,
+	})
+	if len(h.Entries) > historyLen {
+		h.Entries = h.Entries[len(h.Entries)-historyLen:]
+	}
+
+	h.Plays[clip.Clip.ID]++
+
+	return h.save()
+}
+
+// finish fills the duration of the last clip.
+func (h *history) finish(skipped bool) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if len(h.Entries) == 0 {
+		return nil
+	}
+
+	entry := &h.Entries[len(h.Entries)-1]
+	entry.Duration = time.Since(entry.StartedAt)
+	entry.Skipped = skipped
+
+	return h.save()
+}
+
+// recent returns the last n entries, the newest first.
+func (h *history) recent(n int) []HistoryEntry {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	entries := []HistoryEntry{}
+	for i := len(h.Entries) - 1; i >= 0 && len(entries) < n; i-- {
+		entries = append(entries, h.Entries[i])
+	}
+	return entries
+}
+
+// counts returns a copy of the play counts.
+func (h *history) counts() map[string]int64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return maps.Clone(h.Plays)
+}
+
+// plays returns the play counts, the most played first.
+func (h *history) plays(titles map[string]string) []PlayCount {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	counts := []PlayCount{}
+	for id, count := range h.Plays {
+		counts = append(counts, PlayCount{ClipID: id, Title: titles[id], Count: count})
+	}
+
+	sort.Slice(counts, func(i, j int) bool {
+		if counts[i].Count != counts[j].Count {
+			return counts[i].Count > counts[j].Count
+		}
+		return counts[i].ClipID < counts[j].ClipID
+	})
+
+	return counts
+}
+
+// History returns the last n played clips and the play counts of the clips.
+func (w *Worker) History(n int) map[string]any {
+	titles := make(map[string]string)
+	for _, clip := range w.playlist.Load().PlaylistClips {
+		titles[clip.Clip.ID] = clip.Clip.Title
+	}
+
+	return map[string]any{
+		"recent": w.history.recent(n),
+		"plays":  w.history.plays(titles),
+	}
+}
@@ -0,0 +1,52 @@
+package suno
+
+import (
+	"testing"
+)
+
+func TestHistory(t *testing.T) {
+	dir := t.TempDir()
+	clips := testClips(3)
+
+	h := newHistory(dir)
+	for i := range historyLen + 10 {
+		err := h.start(clips[i%2], int32(i))
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = h.finish(i%3 == 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	recent := h.recent(5)
+	if len(recent) != 5 {
+		t.Fatalf("recent %d, want 5", len(recent))
+	}
+	if recent[0].Listeners != historyLen+9 || recent[0].Skipped != ((historyLen+9)%3 == 0) {
+		t.Fatalf("newest entry %+v", recent[0])
+	}
+
+	// reload from the dir
+	h = newHistory(dir)
+	err := h.load()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(h.Entries) != historyLen {
+		t.Fatalf("entries %d, want %d", len(h.Entries), historyLen)
+	}
+
+	plays := h.plays(map[string]string{clips[0].Clip.ID: "first"})
+	if len(plays) != 2 {
+		t.Fatalf("plays %+v", plays)
+	}
+	if plays[0].ClipID != clips[0].Clip.ID || plays[0].Title != "first" || plays[0].Count != (historyLen+10+1)/2 {
+		t.Fatalf("most played %+v", plays[0])
+	}
+	if plays[1].Count != (historyLen+10)/2 {
+		t.Fatalf("least played %+v", plays[1])
+	}
+}
@@ -0,0 +1,313 @@
+package suno
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/hellodword/suno-radio/internal/mp3"
+	"github.com/hellodword/suno-radio/internal/mpegts"
+	"github.com/teivah/broadcast"
+)
+
+const (
+	HLSPlaylistMIMEType = "application/vnd.apple.mpegurl"
+
+	hlsTargetDuration = time.Second * 6
+	// segments in the playlist, the older ones are kept as long for the slow clients
+	hlsWindow = 6
+	// the segmenter stops once no client asked for a while
+	hlsIdle    = time.Second * 30
+	hlsSession = "hls"
+)
+
+var ErrSegmentNotFound = errors.New("segment not found")
+
+type hlsSegment struct {
+	seq           int64
+	start         time.Time
+	duration      time.Duration
+	title         string
+	discontinuity bool
+	data          []byte
+}
+
+// hlsSegmenter cuts the MP3 frames into MPEG-TS segments while the HLS clients are polling,
+// it counts as a single listener.
+type hlsSegmenter struct {
+	mu         sync.Mutex
+	running    bool
+	lastAccess time.Time
+	segments   []*hlsSegment
+	seq        int64
+	// the EXT-X-DISCONTINUITY tags dropped from the segments
+	discontinuities int64
+	// closed and replaced when a segment is added
+	added chan struct{}
+}
+
+func newHLSSegmenter() *hlsSegmenter {
+	return &hlsSegmenter{added: make(chan struct{})}
+}
+
+func (s *hlsSegmenter) add(seg *hlsSegment) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	seg.seq = s.seq
+	s.seq++
+	s.segments = append(s.segments, seg)
+
+	for len(s.segments) > hlsWindow*2 {
+		if s.segments[0].discontinuity {
+			s.discontinuities++
+		}
+		s.segments = s.segments[1:]
+	}
+
+	close(s.added)
+	s.added = make(chan struct{})
+}
+
+// stop drops the segments, the next ones start after a discontinuity.
+func (s *hlsSegmenter) stop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.running = false
+	for _, seg := range s.segments {
+		if seg.discontinuity {
+			s.discontinuities++
+		}
+	}
+	s.segments = nil
+}
+
+func (s *hlsSegmenter) segment(seq int64) *hlsSegment {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, seg := range s.segments {
+		if seg.seq == seq {
+			return seg
+		}
+	}
+	return nil
+}
+
+// playlist renders the live sliding window.
+func (s *hlsSegmenter) playlist() []byte {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	segments := s.segments[max(0, len(s.segments)-hlsWindow):]
+
+	discontinuities := s.discontinuities
+	for _, seg := range s.segments[:len(s.segments)-len(segments)] {
+		if seg.discontinuity {
+			discontinuities++
+		}
+	}
+
+	target := hlsTargetDuration
+	for _, seg := range segments {
+		target = max(target, seg.duration)
+	}
+
+	var b bytes.Buffer
+	b.WriteString("#EXTM3U\n")
+	b.WriteString("#EXT-X-VERSION:3\n")
+	fmt.Fprintf(&b, "#EXT-X-TARGETDURATION:%d\n", int(math.Ceil(target.Seconds())))
+	if len(segments) > 0 {
+		fmt.Fprintf(&b, "#EXT-X-MEDIA-SEQUENCE:%d\n", segments[0].seq)
+	}
+	fmt.Fprintf(&b, "#EXT-X-DISCONTINUITY-SEQUENCE:%d\n", discontinuities)
+
+	for _, seg := range segments {
+		if seg.discontinuity {
+			b.WriteString("#EXT-X-DISCONTINUITY\n")
+		}
+		fmt.Fprintf(&b, "#EXT-X-PROGRAM-DATE-TIME:%s\n", seg.start.UTC().Format("2006-01-02T15:04:05.000Z"))
+		fmt.Fprintf(&b, "#EXTINF:%.3f,%s\n", seg.duration.Seconds(), strings.ReplaceAll(seg.title, "\n", " "))
+		fmt.Fprintf(&b, "%d.ts\n", seg.seq)
+	}
+
+	return b.Bytes()
+}
+
+// touchHLS starts the segmenter if it's not running, ErrWorkerClosed once the playlist is removed.
+func (w *Worker) touchHLS() error {
+	// the segmenter is added to the wait group of Close
+	w.closeMu.Lock()
+	defer w.closeMu.Unlock()
+
+	if atomic.LoadInt32(&w.canceled) != 0 {
+		return ErrWorkerClosed
+	}
+
+	s := w.hls
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.lastAccess = time.Now()
+	if s.running {
+		return nil
+	}
+
+	leave, err := w.join(hlsSession, w.Options())
+	if err != nil {
+		return err
+	}
+	s.running = true
+
+	listener := w.mp3Broadcaster.Listener(4)
+
+	w.wg.Add(1)
+	go func() {
+		defer w.wg.Done()
+		defer leave()
+		defer s.stop()
+		defer listener.Close()
+		w.segmentHLS(listener)
+	}()
+
+	return nil
+}
+
+func (w *Worker) segmentHLS(listener *broadcast.Listener[*mp3Chunk]) {
+	s := w.hls
+
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	var (
+		seg        *hlsSegment
+		clip       *PlaylistClip
+		buf        bytes.Buffer
+		muxer      *mpegts.Muxer
+		streamType byte
+		sampleRate int
+		// the PTS and the PCR continue across the segments
+		elapsed       time.Duration
+		discontinuity = true
+	)
+
+	cut := func() {
+		if seg == nil {
+			return
+		}
+		seg.data = bytes.Clone(buf.Bytes())
+		s.add(seg)
+		seg = nil
+	}
+
+	for {
+		select {
+		case <-ticker.C:
+			s.mu.Lock()
+			idle := time.Since(s.lastAccess) > hlsIdle
+			s.mu.Unlock()
+			if idle || atomic.LoadInt32(&w.canceled) != 0 {
+				return
+			}
+		case chunk := <-listener.Ch():
+			if chunk == nil {
+				return
+			}
+
+			for _, frame := range chunk.frames {
+				typ := byte(mpegts.StreamTypeMPEG2Audio)
+				if frame.Header.Version() == mp3.Version1 {
+					typ = mpegts.StreamTypeMPEG1Audio
+				}
+
+				// the players reset the decoder on a discontinuity only
+				if sampleRate != 0 && (typ != streamType || frame.Header.SampleRate() != sampleRate) {
+					cut()
+					discontinuity = true
+				}
+
+				if seg != nil && (chunk.clip != clip || seg.duration >= hlsTargetDuration) {
+					cut()
+				}
+
+				if seg == nil {
+					seg = &hlsSegment{start: time.Now(), title: clipTitle(chunk.clip), discontinuity: discontinuity}
+					discontinuity = false
+					clip = chunk.clip
+					streamType = typ
+					sampleRate = frame.Header.SampleRate()
+
+					buf.Reset()
+					muxer = mpegts.NewMuxer(&buf, streamType)
+					err := muxer.WriteTables()
+					if err != nil {
+						w.logger.Error("hls tables", "err", err)
+						return
+					}
+				}
+
+				pts := mpegts.ClockRate + int64(elapsed)*mpegts.ClockRate/int64(time.Second)
+				err := muxer.WritePES(pts, frame.Data)
+				if err != nil {
+					w.logger.Error("hls pes", "err", err)
+					return
+				}
+
+				seg.duration += frame.Header.Duration()
+				elapsed += frame.Header.Duration()
+			}
+		}
+	}
+}
+
+// HLSPlaylist returns the live m3u8 of the MPEG-TS segments, it waits for the first segment
+// if the segmenter has just started.
+func (w *Worker) HLSPlaylist(ctx context.Context) ([]byte, error) {
+	err := w.touchHLS()
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, hlsTargetDuration*2)
+	defer cancel()
+
+	for {
+		w.hls.mu.Lock()
+		ready := len(w.hls.segments) > 0
+		added := w.hls.added
+		w.hls.mu.Unlock()
+
+		if ready {
+			break
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ErrNotPlaying
+		case <-added:
+		}
+	}
+
+	return w.hls.playlist(), nil
+}
+
+// HLSSegment returns the MPEG-TS segment of the seq in the playlist.
+func (w *Worker) HLSSegment(seq int64) ([]byte, error) {
+	err := w.touchHLS()
+	if err != nil {
+		return nil, err
+	}
+
+	seg := w.hls.segment(seq)
+	if seg == nil {
+		return nil, ErrSegmentNotFound
+	}
+	return seg.data, nil
+}
@@ -0,0 +1,85 @@
+package suno
+
+import (
+	"context"
+	"encoding/binary"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hellodword/suno-radio/internal/mp3"
+	"github.com/hellodword/suno-radio/internal/mpegts"
+)
+
+func TestHLS(t *testing.T) {
+	w := newTestWorker(PlaylistOptions{})
+	defer w.Close()
+
+	clips := testClips(2)
+	clips[0].Clip.Title = "first"
+	clips[1].Clip.Title = "second"
+
+	// the listener is subscribed before it returns
+	err := w.touchHLS()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// MPEG1 Layer III, 128kbps, 44100Hz, 26ms per frame
+	h := mp3.Header(0xfffb9000)
+	chunk := func(clip *PlaylistClip) *mp3Chunk {
+		c := &mp3Chunk{clip: clip}
+		for range 38 {
+			frame := &mp3.Frame{Header: h, Data: make([]byte, h.Len())}
+			binary.BigEndian.PutUint32(frame.Data, uint32(h))
+			c.frames = append(c.frames, frame)
+		}
+		return c
+	}
+
+	// 10s of each clip, the segments are cut at 6s and at the clip change
+	for i := range 20 {
+		w.mp3Broadcaster.Notify(chunk(clips[i/10]))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	b, err := w.HLSPlaylist(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the last chunk may still be in the segmenter
+	for w.hls.segment(2) == nil {
+		time.Sleep(time.Millisecond)
+	}
+	b = w.hls.playlist()
+	playlist := string(b)
+
+	for _, want := range []string{
+		"#EXTM3U\n",
+		"#EXT-X-TARGETDURATION:7\n",
+		"#EXT-X-MEDIA-SEQUENCE:0\n",
+		"#EXT-X-DISCONTINUITY-SEQUENCE:0\n",
+		"#EXT-X-DISCONTINUITY\n#EXT-X-PROGRAM-DATE-TIME:",
+		"#EXTINF:6.008,first\n0.ts\n",
+		"#EXTINF:3.918,first\n1.ts\n",
+		"#EXTINF:6.008,second\n2.ts\n",
+	} {
+		if !strings.Contains(playlist, want) {
+			t.Fatalf("%q not in\n%s", want, playlist)
+		}
+	}
+
+	seg, err := w.HLSSegment(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(seg)%mpegts.PacketSize != 0 || seg[0] != 0x47 {
+		t.Fatalf("segment %d bytes", len(seg))
+	}
+
+	if _, err = w.HLSSegment(100); err != ErrSegmentNotFound {
+		t.Fatalf("segment 100: %v", err)
+	}
+}
@@ -0,0 +1,115 @@
+package suno
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+)
+
+// DefaultIcyMetaInt is the bytes of audio between the ICY metadata blocks.
+const DefaultIcyMetaInt = 16000
+
+// the length byte counts the blocks of 16 bytes
+const icyMaxMetaLen = 255 * 16
+
+// ClipWriter is notified by Worker.Stream before the pages of a new clip are written.
+type ClipWriter interface {
+	io.Writer
+	SetClip(clip *PlaylistClip)
+}
+
+// IcyWriter interleaves the ICY metadata blocks into the stream every metaint bytes,
+// the StreamTitle is only sent again when the clip changes.
+type IcyWriter struct {
+	w       io.Writer
+	metaint int
+	left    int
+
+	mu      sync.Mutex
+	meta    string
+	written bool
+}
+
+var _ ClipWriter = (*IcyWriter)(nil)
+
+func NewIcyWriter(w io.Writer, metaint int) *IcyWriter {
+	if metaint <= 0 {
+		metaint = DefaultIcyMetaInt
+	}
+	return &IcyWriter{w: w, metaint: metaint, left: metaint}
+}
+
+// icyEscape drops the quotes, the ICY metadata has no escaping.
+func icyEscape(s string) string {
+	return strings.ReplaceAll(s, "'", "’")
+}
+
+// clipTitle is the "artist - title" of the players.
+func clipTitle(clip *PlaylistClip) string {
+	if clip.Clip.DisplayName != "" {
+		return clip.Clip.DisplayName + " - " + clip.Clip.Title
+	}
+	return clip.Clip.Title
+}
+
+func (iw *IcyWriter) SetClip(clip *PlaylistClip) {
+	title, url := icyEscape(clipTitle(clip)), icyEscape(clip.URL())
+	meta := fmt.Sprintf("StreamTitle='%s';StreamUrl='%s';", title, url)
+	if excess := len(meta) - icyMaxMetaLen; excess > 0 {
+		title = strings.ToValidUTF8(title[:max(0, len(title)-excess)], "")
+		meta = fmt.Sprintf("StreamTitle='%s';StreamUrl='%s';", title, url)
+	}
+
+	iw.mu.Lock()
+	defer iw.mu.Unlock()
+	if meta != iw.meta {
+		iw.meta = meta
+		iw.written = false
+	}
+}
+
+// metadata returns the next block, a single zero byte if nothing changed.
+func (iw *IcyWriter) metadata() []byte {
+	iw.mu.Lock()
+	defer iw.mu.Unlock()
+
+	if iw.written || iw.meta == "" {
+		return []byte{0}
+	}
+	iw.written = true
+
+	meta := iw.meta
+	if len(meta) > icyMaxMetaLen {
+		meta = meta[:icyMaxMetaLen]
+	}
+
+	n := (len(meta) + 15) / 16
+	block := make([]byte, 1+n*16)
+	block[0] = byte(n)
+	copy(block[1:], meta)
+	return block
+}
+
+func (iw *IcyWriter) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		n := min(len(p), iw.left)
+		n, err := iw.w.Write(p[:n])
+		written += n
+		iw.left -= n
+		p = p[n:]
+		if err != nil {
+			return written, err
+		}
+
+		if iw.left == 0 {
+			_, err = iw.w.Write(iw.metadata())
+			if err != nil {
+				return written, err
+			}
+			iw.left = iw.metaint
+		}
+	}
+	return written, nil
+}
@@ -0,0 +1,55 @@
+package suno
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestIcyWriter(t *testing.T) {
+	var buf bytes.Buffer
+	iw := NewIcyWriter(&buf, 10)
+
+	clip := testClips(1)[0]
+	clip.Clip.Title = "it's a song"
+	clip.Clip.DisplayName = "someone"
+	iw.SetClip(clip)
+
+	audio := bytes.Repeat([]byte{'a'}, 25)
+	for _, n := range []int{3, 7, 15} {
+		written, err := iw.Write(audio[:n])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if written != n {
+			t.Fatalf("written %d, want %d", written, n)
+		}
+	}
+
+	b := buf.Bytes()
+	if !bytes.Equal(b[:10], audio[:10]) {
+		t.Fatalf("first interval %q", b[:10])
+	}
+
+	n := int(b[10])
+	meta := string(bytes.TrimRight(b[11:11+n*16], "\x00"))
+	want := "StreamTitle='someone - it’s a song';StreamUrl='" + clip.URL() + "';"
+	if meta != want {
+		t.Fatalf("metadata %q, want %q", meta, want)
+	}
+
+	// the title is sent once
+	rest := b[11+n*16:]
+	if len(rest) != 10+1+5 || !bytes.Equal(rest[:10], audio[:10]) || rest[10] != 0 {
+		t.Fatalf("second interval %q", rest)
+	}
+
+	clip.Clip.Title = strings.Repeat("x", icyMaxMetaLen)
+	iw.SetClip(clip)
+	buf.Reset()
+	_, _ = iw.Write(audio[:5])
+	b = buf.Bytes()
+	if len(b) != 5+1+icyMaxMetaLen || b[5] != 255 || !bytes.HasSuffix(b, []byte("';")) {
+		t.Fatalf("long metadata %d bytes", len(b))
+	}
+}
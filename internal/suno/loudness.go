@@ -0,0 +1,47 @@
+package suno
+
+import (
+	"math"
+
+	"github.com/hellodword/suno-radio/internal/mp3toogg"
+	"github.com/hellodword/suno-radio/internal/ogg"
+)
+
+// R128_TRACK_GAIN takes the players from the output gain to this reference, RFC 7845 5.2.1
+const r128Reference = -23.0
+
+// loadLoudness reads the analysis of the clip before it's played.
+func (w *Worker) loadLoudness(clip *PlaylistClip, pogg string) {
+	l, err := mp3toogg.ReadLoudness(pogg)
+	if err != nil {
+		w.loudness.Delete(clip.Clip.ID)
+		return
+	}
+	w.loudness.Store(clip.Clip.ID, l)
+}
+
+// clipGain is the gain in dB from the integrated loudness of the clip to the target,
+// false if the normalization is off or the clip has no analysis.
+func (w *Worker) clipGain(clip *PlaylistClip, opts PlaylistOptions) (float64, bool) {
+	if opts.TargetLUFS == 0 {
+		return 0, false
+	}
+
+	v, ok := w.loudness.Load(clip.Clip.ID)
+	if !ok {
+		return 0, false
+	}
+	return opts.TargetLUFS - v.(*mp3toogg.Loudness).Integrated, true
+}
+
+// clipHeader sets the output gain of the clip in the OpusHead of its logical stream,
+// the clip is at the target loudness after it.
+func (w *Worker) clipHeader(idh *ogg.IDHeader, clip *PlaylistClip, opts PlaylistOptions) {
+	gain, _ := w.clipGain(clip, opts)
+	idh.OutputGainQ7_8 = gainQ7_8(opts.Gain + gain)
+}
+
+func gainQ7_8(db float64) int16 {
+	q := math.Round(db * 256)
+	return int16(max(math.MinInt16, min(math.MaxInt16, q)))
+}
@@ -0,0 +1,104 @@
+package suno
+
+import (
+	"context"
+	"io"
+	"sync/atomic"
+	"time"
+
+	"github.com/hellodword/suno-radio/internal/mp3"
+)
+
+type mp3Chunk struct {
+	clip   *PlaylistClip
+	frames []*mp3.Frame
+}
+
+// mp3Follower reads the MP3 frames of the clip along with the ogg pages,
+// so the MP3 listeners hear the same schedule without re-encoding.
+type mp3Follower struct {
+	d    *mp3.Decoder
+	pos  time.Duration
+	done bool
+}
+
+func newMP3Follower(r io.Reader) *mp3Follower {
+	return &mp3Follower{d: mp3.NewDecoder(r)}
+}
+
+// until returns the frames up to the position t of the clip.
+func (f *mp3Follower) until(t time.Duration) []*mp3.Frame {
+	var frames []*mp3.Frame
+	for !f.done && f.pos < t {
+		frame, err := f.d.Decode()
+		if err != nil {
+			f.done = true
+			break
+		}
+		frames = append(frames, frame)
+		f.pos += frame.Header.Duration()
+	}
+	return frames
+}
+
+// StreamMP3 writes the MP3 frames of the clips until the ctx is done,
+// the ID3 tags and the Xing/Info frames are stripped between the clips.
+func (w *Worker) StreamMP3(session string, ctx context.Context, writer io.Writer) error {
+	leave, err := w.join(session, w.Options())
+	if err != nil {
+		return err
+	}
+	defer leave()
+
+	burst, listener, err := w.subscribeMP3()
+	if err != nil {
+		return err
+	}
+	defer listener.Close()
+
+	clipWriter, _ := writer.(ClipWriter)
+	var lastClip *PlaylistClip
+
+	write := func(chunk *mp3Chunk) error {
+		if clipWriter != nil && chunk.clip != lastClip {
+			clipWriter.SetClip(chunk.clip)
+			lastClip = chunk.clip
+		}
+
+		for _, frame := range chunk.frames {
+			_, err := writer.Write(frame.Data)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	// the new listener starts with the frames of the last seconds
+	for _, chunk := range burst {
+		err := write(chunk)
+		if err != nil {
+			return err
+		}
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return context.Canceled
+		case chunk := <-listener.Ch():
+			if chunk == nil {
+				return context.Canceled
+			}
+
+			if atomic.LoadInt32(&w.canceled) != 0 {
+				return context.Canceled
+			}
+
+			err := write(chunk)
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
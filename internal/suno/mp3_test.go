@@ -0,0 +1,80 @@
+package suno
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/hellodword/suno-radio/internal/mp3"
+)
+
+// testMP3 is n frames of MPEG1 Layer III, 128kbps, 44100Hz, 26ms per frame.
+func testMP3(n int) *bytes.Buffer {
+	h := mp3.Header(0xfffb9000)
+
+	var file bytes.Buffer
+	for range n {
+		frame := make([]byte, h.Len())
+		binary.BigEndian.PutUint32(frame, uint32(h))
+		file.Write(frame)
+	}
+	return &file
+}
+
+func TestMP3Follower(t *testing.T) {
+	f := newMP3Follower(testMP3(100))
+
+	frames := f.until(time.Second)
+	// ceil(1s / 26.122ms) = 39
+	if len(frames) != 39 {
+		t.Fatalf("%d frames in the first second", len(frames))
+	}
+
+	if frames = f.until(time.Second); len(frames) != 0 {
+		t.Fatalf("%d frames again", len(frames))
+	}
+
+	frames = f.until(time.Hour)
+	if len(frames) != 61 || !f.done {
+		t.Fatalf("%d frames left", len(frames))
+	}
+}
+
+func TestStreamMP3Held(t *testing.T) {
+	fakeOpus(t)
+
+	clips := testClips(1)
+	w := newTestWorker(PlaylistOptions{Crossfade: time.Millisecond * 100})
+	atomic.StoreInt32(&w.streamCount, 1)
+
+	listener := w.mp3Broadcaster.Listener(64)
+	defer listener.Close()
+
+	frames := func() int {
+		var n int
+		for len(listener.Ch()) > 0 {
+			n += len((<-listener.Ch()).frames)
+		}
+		return n
+	}
+
+	// 3 pages of 100ms, the last one is held for the fade
+	f := bytes.NewReader(testOggClip(t, 0, []int{5, 5, 5}, 0))
+	_, err := w.streamOgg(context.Background(), clips[0], f, newMP3Follower(testMP3(100)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// ceil(200ms / 26.122ms) = 8
+	if n := frames(); n != 8 {
+		t.Fatalf("%d frames with the first 2 pages", n)
+	}
+
+	w.flushFade(context.Background())
+	if n := frames(); n != 4 {
+		t.Fatalf("%d frames with the held page", n)
+	}
+}
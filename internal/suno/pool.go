@@ -7,20 +7,43 @@ import (
 	"log/slog"
 	"os"
 	"path"
+	"slices"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
 type WorkerPool struct {
 	pool sync.Map
 
-	dir      string
-	interval time.Duration
+	client *Client
+	dir    string
+	// the Interval and MaxClips of the playlists without their own
+	defaults atomic.Pointer[PlaylistOptions]
 	logger   *slog.Logger
+	registry *registry
+
+	// serializes the adds and removes, so two playlists can't take the same alias
+	mu sync.Mutex
+	// ids of the playlists from the config
+	static map[string]struct{}
 }
 
-func NewWorkerPool(logger *slog.Logger, interval time.Duration, dir string) *WorkerPool {
-	return &WorkerPool{dir: dir, interval: interval, logger: logger}
+// ErrAliasConflict is returned when the alias is already used by another playlist.
+var ErrAliasConflict = errors.New("alias conflict")
+
+func NewWorkerPool(logger *slog.Logger, client *Client, interval time.Duration, maxClips int, dir string) *WorkerPool {
+	p := &WorkerPool{client: client, dir: dir, logger: logger,
+		registry: newRegistry(dir),
+		static:   make(map[string]struct{}),
+	}
+	p.SetDefaults(interval, maxClips)
+	return p
+}
+
+// SetDefaults changes the defaults, the running workers get them on the next Sync.
+func (p *WorkerPool) SetDefaults(interval time.Duration, maxClips int) {
+	p.defaults.Store(&PlaylistOptions{Interval: interval, MaxClips: maxClips})
 }
 
 func (p *WorkerPool) Contains(idOrAlias string) bool {
@@ -35,16 +58,53 @@ func (p *WorkerPool) Contains(idOrAlias string) bool {
 	return found
 }
 
-func (p *WorkerPool) Remove(idOrAlias string) error {
+// conflict returns the worker other than id that answers to alias.
+func (p *WorkerPool) conflict(id, alias string) *Worker {
+	var w *Worker
+	p.pool.Range(func(key, value any) bool {
+		if value.(*Worker).ID() != id && (value.(*Worker).ID() == alias || value.(*Worker).Alias() == alias) {
+			w = value.(*Worker)
+			return false
+		}
+		return true
+	})
+	return w
+}
+
+// Remove stops the worker and forgets it if it was added at runtime,
+// the downloaded clips are removed too if purge.
+func (p *WorkerPool) Remove(idOrAlias string, purge bool) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
 	w := p.Get(idOrAlias)
-	if w != nil {
-		p.pool.Delete(w.ID())
-		return w.Close()
+	if w == nil {
+		return nil
+	}
+
+	p.pool.Delete(w.ID())
+	err := w.Close()
+	if err != nil {
+		return err
+	}
+
+	err = p.registry.delete(w.ID())
+	if err != nil {
+		return err
+	}
+
+	if purge {
+		return os.RemoveAll(path.Join(p.dir, w.ID()))
 	}
+
 	return nil
 }
 
 func (p *WorkerPool) Get(idOrAlias string) *Worker {
+	if v, ok := p.pool.Load(idOrAlias); ok {
+		return v.(*Worker)
+	}
+
 	var w *Worker
 	p.pool.Range(func(key, value any) bool {
 		if value.(*Worker).ID() == idOrAlias || value.(*Worker).Alias() == idOrAlias {
@@ -59,17 +119,198 @@ func (p *WorkerPool) Get(idOrAlias string) *Worker {
 func (p *WorkerPool) Infos() PlaylistInfos {
 	var infos = make(PlaylistInfos)
 	p.pool.Range(func(key, value any) bool {
+		if value.(*Worker).Options().Visibility == VisibilityUnlisted {
+			return true
+		}
 		infos[value.(*Worker).Alias()] = value.(*Worker).Info()
 		return true
 	})
 	return infos
 }
 
-func (p *WorkerPool) Add(ctx context.Context, id, alias string) error {
-	if p.Contains(id) {
+func (p *WorkerPool) resolve(opts PlaylistOptions) PlaylistOptions {
+	defaults := p.defaults.Load()
+
+	if opts.Interval <= 0 {
+		opts.Interval = defaults.Interval
+	}
+
+	if opts.MaxClips == 0 {
+		opts.MaxClips = defaults.MaxClips
+	}
+
+	if opts.Order == "" {
+		opts.Order = OrderShuffle
+	}
+
+	if opts.Visibility == "" {
+		opts.Visibility = VisibilityPublic
+	}
+
+	return opts
+}
+
+// Register adds the playlist and persists it, so it's restored on the next boot.
+func (p *WorkerPool) Register(ctx context.Context, id, alias string, opts PlaylistOptions) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	err := p.add(ctx, id, alias, opts)
+	if err != nil {
+		return err
+	}
+
+	return p.registry.put(&PlaylistEntry{ID: id, Alias: alias, Options: opts, AddedAt: time.Now()})
+}
+
+// Sync makes the playlists from the config match entries,
+// the ones added at runtime only get the new defaults.
+// Nothing is applied if an alias is used by a playlist that stays in the pool.
+func (p *WorkerPool) Sync(ctx context.Context, entries []*PlaylistEntry) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	registered, err := p.registry.load()
+	if err != nil {
+		return err
+	}
+	isRegistered := func(id string) bool {
+		return slices.ContainsFunc(registered, func(entry *PlaylistEntry) bool { return entry.ID == id })
+	}
+
+	static := make(map[string]struct{})
+	for _, entry := range entries {
+		static[entry.ID] = struct{}{}
+	}
+
+	// the playlists of the config can swap their aliases, or take the alias of one removed from the config
+	for _, entry := range entries {
+		w := p.conflict(entry.ID, entry.Alias)
+		if w == nil {
+			continue
+		}
+		if _, ok := static[w.ID()]; ok {
+			continue
+		}
+		if _, ok := p.static[w.ID()]; ok && !isRegistered(w.ID()) {
+			continue
+		}
+		return fmt.Errorf("%w: %s of %s is used by %s", ErrAliasConflict, entry.Alias, entry.ID, w.ID())
+	}
+
+	var errs []error
+
+	for id := range p.static {
+		if _, ok := static[id]; ok {
+			continue
+		}
+
+		if isRegistered(id) {
+			continue
+		}
+
+		p.logger.InfoContext(ctx, "pool removing", "id", id)
+		w := p.Get(id)
+		if w != nil {
+			p.pool.Delete(id)
+			err := w.Close()
+			if err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+
+	// renamed before the adds, a new playlist can take the old alias
+	for _, entry := range entries {
+		v, ok := p.pool.Load(entry.ID)
+		if !ok {
+			continue
+		}
+		w := v.(*Worker)
+		if w.Alias() != entry.Alias {
+			p.logger.InfoContext(ctx, "pool renaming", "id", entry.ID, "from", w.Alias(), "alias", entry.Alias)
+			w.alias.Store(&entry.Alias)
+		}
+		w.SetOptions(p.resolve(entry.Options))
+	}
+
+	for _, entry := range entries {
+		if _, ok := p.pool.Load(entry.ID); ok {
+			continue
+		}
+
+		p.logger.InfoContext(ctx, "pool adding", "id", entry.ID, "alias", entry.Alias)
+		err := p.add(ctx, entry.ID, entry.Alias, entry.Options)
+		if err != nil {
+			p.logger.ErrorContext(ctx, "pool add", "id", entry.ID, "alias", entry.Alias, "err", err)
+			errs = append(errs, err)
+			continue
+		}
+		p.logger.InfoContext(ctx, "pool added", "id", entry.ID, "alias", entry.Alias)
+	}
+
+	for _, entry := range registered {
+		if _, ok := static[entry.ID]; ok {
+			continue
+		}
+		if v, ok := p.pool.Load(entry.ID); ok {
+			v.(*Worker).SetOptions(p.resolve(entry.Options))
+		}
+	}
+
+	p.static = static
+
+	return errors.Join(errs...)
+}
+
+// Restore adds the playlists registered in the previous runs,
+// a playlist from the config with the same id wins.
+func (p *WorkerPool) Restore(ctx context.Context) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	entries, err := p.registry.load()
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, entry := range entries {
+		if _, ok := p.pool.Load(entry.ID); ok {
+			continue
+		}
+
+		p.logger.InfoContext(ctx, "pool restoring", "id", entry.ID, "alias", entry.Alias)
+		err = p.add(ctx, entry.ID, entry.Alias, entry.Options)
+		if err != nil {
+			p.logger.ErrorContext(ctx, "pool restore", "id", entry.ID, "alias", entry.Alias, "err", err)
+			errs = append(errs, fmt.Errorf("restore %s: %w", entry.ID, err))
+			continue
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// Add starts a worker for the playlist, it fails with ErrAliasConflict if another playlist uses the alias.
+func (p *WorkerPool) Add(ctx context.Context, id, alias string, opts PlaylistOptions) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.add(ctx, id, alias, opts)
+}
+
+func (p *WorkerPool) add(ctx context.Context, id, alias string, opts PlaylistOptions) error {
+	if _, ok := p.pool.Load(id); ok {
 		return nil
 	}
 
+	if w := p.conflict(id, alias); w != nil {
+		return fmt.Errorf("%w: %s is used by %s", ErrAliasConflict, alias, w.ID())
+	}
+
+	opts = p.resolve(opts)
+
 	dir := path.Join(p.dir, id)
 
 	stat, err := os.Stat(dir)
@@ -83,7 +324,7 @@ func (p *WorkerPool) Add(ctx context.Context, id, alias string) error {
 		return err
 	}
 
-	worker, err := NewWorker(ctx, p.logger.With("id", id).With("alias", alias), id, alias, p.interval, dir)
+	worker, err := NewWorker(ctx, p.logger.With("id", id).With("alias", alias), p.client, id, alias, opts, dir)
 	if err != nil {
 		return err
 	}
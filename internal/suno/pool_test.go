@@ -0,0 +1,265 @@
+package suno
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"strings"
+	"testing"
+	"time"
+)
+
+const (
+	testPoolID1 = "11111111-1111-4111-8111-111111111111"
+	testPoolID2 = "22222222-2222-4222-8222-222222222222"
+	testPoolID3 = "33333333-3333-4333-8333-333333333333"
+)
+
+// newTestPool serves an empty playlist for any id, the workers have nothing to download.
+func newTestPool(t *testing.T, dir string) *WorkerPool {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, ok := strings.CutPrefix(r.URL.Path, "/api/playlist/")
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		var p Playlist
+		p.ID = strings.TrimSuffix(id, "/")
+		p.CurrentPage = 1
+		_ = json.NewEncoder(w).Encode(&p)
+	}))
+	t.Cleanup(s.Close)
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	p := NewWorkerPool(logger, newTestClient(s.URL), time.Millisecond*10, -1, dir)
+	t.Cleanup(func() { p.Close() })
+	return p
+}
+
+func TestPoolRestore(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+
+	p := newTestPool(t, dir)
+	if err := p.Register(ctx, testPoolID1, "one", PlaylistOptions{Order: OrderPlaylist}); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Register(ctx, testPoolID2, "two", PlaylistOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	p.Close()
+
+	// restart
+	p = newTestPool(t, dir)
+	if err := p.Restore(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	w := p.Get("one")
+	if w == nil || w.ID() != testPoolID1 {
+		t.Fatalf("got %v for one", w)
+	}
+	if order := w.Options().Order; order != OrderPlaylist {
+		t.Fatalf("got order %s, expected the registered %s", order, OrderPlaylist)
+	}
+	if w := p.Get(testPoolID2); w == nil || w.Alias() != "two" {
+		t.Fatalf("got %v for %s", w, testPoolID2)
+	}
+}
+
+func TestPoolRemove(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+
+	p := newTestPool(t, dir)
+	for id, alias := range map[string]string{testPoolID1: "one", testPoolID2: "two"} {
+		if err := p.Register(ctx, id, alias, PlaylistOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := p.Remove("one", false); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Remove(testPoolID2, true); err != nil {
+		t.Fatal(err)
+	}
+	if p.Contains("one") || p.Contains("two") {
+		t.Fatal("removed playlist still in the pool")
+	}
+
+	// the clips are kept unless purged
+	if _, err := os.Stat(path.Join(dir, testPoolID1, playlistFile)); err != nil {
+		t.Fatal("unexpected purge:", err)
+	}
+	if _, err := os.Stat(path.Join(dir, testPoolID2)); !errors.Is(err, os.ErrNotExist) {
+		t.Fatal("expected purge, got", err)
+	}
+
+	// neither comes back on the next boot
+	p = newTestPool(t, dir)
+	if err := p.Restore(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if p.Contains(testPoolID1) || p.Contains(testPoolID2) {
+		t.Fatal("removed playlist restored")
+	}
+}
+
+func TestPoolAliasConflict(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+
+	p := newTestPool(t, dir)
+	if err := p.Register(ctx, testPoolID1, "one", PlaylistOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := p.Register(ctx, testPoolID2, "one", PlaylistOptions{}); !errors.Is(err, ErrAliasConflict) {
+		t.Fatal("expected ErrAliasConflict, got", err)
+	}
+	if err := p.Add(ctx, testPoolID2, testPoolID1, PlaylistOptions{}); !errors.Is(err, ErrAliasConflict) {
+		t.Fatal("expected ErrAliasConflict for an alias equal to an id, got", err)
+	}
+	if p.Contains(testPoolID2) {
+		t.Fatal("conflicting playlist added")
+	}
+
+	// the same playlist again is not a conflict
+	if err := p.Register(ctx, testPoolID1, "one", PlaylistOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	// the config took the alias while the pool was down, the registered playlist is reported
+	if err := p.Register(ctx, testPoolID3, "three", PlaylistOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	p.Close()
+
+	p = newTestPool(t, dir)
+	if err := p.Sync(ctx, []*PlaylistEntry{{ID: testPoolID2, Alias: "three"}}); err != nil {
+		t.Fatal(err)
+	}
+	err := p.Restore(ctx)
+	if !errors.Is(err, ErrAliasConflict) || !strings.Contains(err.Error(), testPoolID3) {
+		t.Fatal("expected ErrAliasConflict for", testPoolID3, "got", err)
+	}
+	if w := p.Get("three"); w == nil || w.ID() != testPoolID2 {
+		t.Fatalf("got %v for three", w)
+	}
+	if w := p.Get("one"); w == nil || w.ID() != testPoolID1 {
+		t.Fatalf("got %v for one", w)
+	}
+}
+
+func TestPoolSync(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+
+	p := newTestPool(t, dir)
+	if err := p.Sync(ctx, []*PlaylistEntry{{ID: testPoolID1, Alias: "one"}, {ID: testPoolID2, Alias: "two"}}); err != nil {
+		t.Fatal(err)
+	}
+	w1, w2 := p.Get("one"), p.Get("two")
+	if w1 == nil || w2 == nil {
+		t.Fatal("playlists not added")
+	}
+
+	// the aliases are swapped on the same workers, the second one is removed and the third added
+	entries := []*PlaylistEntry{{ID: testPoolID1, Alias: "two"}, {ID: testPoolID3, Alias: "one"}}
+	if err := p.Sync(ctx, entries); err != nil {
+		t.Fatal(err)
+	}
+	if w := p.Get("two"); w != w1 {
+		t.Fatalf("got %v for two, expected the renamed %s", w, testPoolID1)
+	}
+	if w := p.Get("one"); w == nil || w.ID() != testPoolID3 {
+		t.Fatalf("got %v for one", w)
+	}
+	if p.Contains(testPoolID2) {
+		t.Fatal("removed playlist still in the pool")
+	}
+}
+
+func TestPoolSyncRegistered(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+
+	p := newTestPool(t, dir)
+	if err := p.Sync(ctx, []*PlaylistEntry{{ID: testPoolID1, Alias: "one"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Register(ctx, testPoolID2, "two", PlaylistOptions{Order: OrderPlaylist}); err != nil {
+		t.Fatal(err)
+	}
+
+	// a reload taking the alias of a registered playlist fails as a whole
+	err := p.Sync(ctx, []*PlaylistEntry{{ID: testPoolID1, Alias: "two"}, {ID: testPoolID3, Alias: "three"}})
+	if !errors.Is(err, ErrAliasConflict) {
+		t.Fatal("expected ErrAliasConflict, got", err)
+	}
+	if w := p.Get("one"); w == nil || w.ID() != testPoolID1 {
+		t.Fatalf("got %v for one", w)
+	}
+	if w := p.Get("two"); w == nil || w.ID() != testPoolID2 {
+		t.Fatalf("got %v for two", w)
+	}
+	if p.Contains(testPoolID3) {
+		t.Fatal("failed reload applied")
+	}
+
+	// the config wins for a registered id, the playlist stays when it leaves the config again
+	if err := p.Sync(ctx, []*PlaylistEntry{{ID: testPoolID1, Alias: "one"}, {ID: testPoolID2, Alias: "two", Options: PlaylistOptions{Order: OrderReverse}}}); err != nil {
+		t.Fatal(err)
+	}
+	if order := p.Get("two").Options().Order; order != OrderReverse {
+		t.Fatalf("got order %s, expected the static %s", order, OrderReverse)
+	}
+	if err := p.Sync(ctx, []*PlaylistEntry{{ID: testPoolID1, Alias: "one"}}); err != nil {
+		t.Fatal(err)
+	}
+	w := p.Get("two")
+	if w == nil {
+		t.Fatal("registered playlist removed")
+	}
+	if order := w.Options().Order; order != OrderPlaylist {
+		t.Fatalf("got order %s, expected the registered %s", order, OrderPlaylist)
+	}
+}
+
+func TestPoolSetDefaults(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+
+	p := newTestPool(t, dir)
+	entries := []*PlaylistEntry{{ID: testPoolID1, Alias: "one"}, {ID: testPoolID2, Alias: "two", Options: PlaylistOptions{MaxClips: 3}}}
+	if err := p.Sync(ctx, entries); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Register(ctx, testPoolID3, "three", PlaylistOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	p.SetDefaults(time.Millisecond*20, 5)
+	if err := p.Sync(ctx, entries); err != nil {
+		t.Fatal(err)
+	}
+
+	for alias, expected := range map[string]PlaylistOptions{
+		"one":   {Interval: time.Millisecond * 20, MaxClips: 5},
+		"two":   {Interval: time.Millisecond * 20, MaxClips: 3},
+		"three": {Interval: time.Millisecond * 20, MaxClips: 5},
+	} {
+		opts := p.Get(alias).Options()
+		if opts.Interval != expected.Interval || opts.MaxClips != expected.MaxClips {
+			t.Fatalf("%s got interval %s and %d clips, expected %s and %d", alias, opts.Interval, opts.MaxClips, expected.Interval, expected.MaxClips)
+		}
+	}
+}
@@ -0,0 +1,208 @@
+package suno
+
+import (
+	"slices"
+	"sync"
+	"time"
+)
+
+const queueHistoryLen = 1024
+
+type queuePlay struct {
+	id string
+	at time.Time
+}
+
+// Queue materializes the upcoming clips of a Worker,
+// so the api can peek at them before they're played.
+type Queue struct {
+	mu       sync.Mutex
+	strategy Strategy
+	// requested by the api, played before the upcoming ones
+	requested []string
+	upcoming  []string
+	played    []queuePlay
+
+	noRepeatSongs  int
+	noRepeatWithin time.Duration
+}
+
+// NewQueue creates a Queue, a clip is not repeated within noRepeatSongs songs
+// or noRepeatWithin, as long as there are other clips to play.
+func NewQueue(strategy Strategy, noRepeatSongs int, noRepeatWithin time.Duration) *Queue {
+	return &Queue{strategy: strategy, noRepeatSongs: noRepeatSongs, noRepeatWithin: noRepeatWithin}
+}
+
+// SetStrategy replaces the strategy and drops the upcoming clips.
+func (q *Queue) SetStrategy(strategy Strategy) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.strategy = strategy
+	q.upcoming = nil
+}
+
+func (q *Queue) SetNoRepeat(songs int, within time.Duration) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.noRepeatSongs, q.noRepeatWithin = songs, within
+}
+
+// fill drops the clips that are gone and draws until there are n upcoming clips.
+func (q *Queue) fill(clips PlaylistClips, n int) map[string]*PlaylistClip {
+	byID := make(map[string]*PlaylistClip, len(clips))
+	for _, clip := range clips {
+		byID[clip.Clip.ID] = clip
+	}
+
+	gone := func(id string) bool {
+		_, ok := byID[id]
+		return !ok
+	}
+	q.requested = slices.DeleteFunc(q.requested, gone)
+	q.upcoming = slices.DeleteFunc(q.upcoming, gone)
+
+	if len(clips) == 0 {
+		return byID
+	}
+
+	for len(q.upcoming) < n {
+		q.upcoming = append(q.upcoming, q.strategy.Next(clips).Clip.ID)
+	}
+
+	return byID
+}
+
+// lastPlayed returns how many songs ago and when the clip was played.
+func (q *Queue) lastPlayed(id string) (int, time.Time, bool) {
+	for i := len(q.played) - 1; i >= 0; i-- {
+		if q.played[i].id == id {
+			return len(q.played) - 1 - i, q.played[i].at, true
+		}
+	}
+	return 0, time.Time{}, false
+}
+
+func (q *Queue) repeated(id string, now time.Time) bool {
+	ago, at, ok := q.lastPlayed(id)
+	if !ok {
+		return false
+	}
+	return ago < q.noRepeatSongs || now.Sub(at) < q.noRepeatWithin
+}
+
+// Next pops the first upcoming clip which is not a repeat,
+// or the least recently played one if they all are.
+func (q *Queue) Next(clips PlaylistClips) *PlaylistClip {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	byID := q.fill(clips, 1)
+	if len(q.upcoming) == 0 {
+		return nil
+	}
+
+	now := time.Now()
+
+	if len(q.requested) > 0 {
+		id := q.requested[0]
+		q.requested = q.requested[1:]
+		q.record(id, now)
+		return byID[id]
+	}
+
+	pick := -1
+	var oldest time.Time
+	for i := 0; ; i++ {
+		// look a little further for a non-repeat
+		if i == len(q.upcoming) {
+			if i >= len(clips)*2 {
+				break
+			}
+			q.fill(clips, i+1)
+		}
+
+		id := q.upcoming[i]
+		if !q.repeated(id, now) {
+			pick = i
+			break
+		}
+		if _, at, _ := q.lastPlayed(id); pick < 0 || at.Before(oldest) {
+			pick, oldest = i, at
+		}
+	}
+
+	id := q.upcoming[pick]
+	if q.repeated(id, now) {
+		// the strategy keeps drawing the repeats, bypass it
+		for _, clip := range clips {
+			if !q.repeated(clip.Clip.ID, now) {
+				id, pick = clip.Clip.ID, -1
+				break
+			}
+		}
+	}
+	if pick >= 0 {
+		q.upcoming = append(q.upcoming[:pick], q.upcoming[pick+1:]...)
+	}
+
+	q.record(id, now)
+
+	return byID[id]
+}
+
+func (q *Queue) record(id string, now time.Time) {
+	q.played = append(q.played, queuePlay{id: id, at: now})
+	if len(q.played) > queueHistoryLen {
+		q.played = q.played[len(q.played)-queueHistoryLen:]
+	}
+}
+
+// Enqueue requests the clip after the other requested ones.
+func (q *Queue) Enqueue(id string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.requested = append(q.requested, id)
+}
+
+// Remove drops the clip from the requested and the upcoming ones,
+// returns false if it's not queued.
+func (q *Queue) Remove(id string) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	n := len(q.requested) + len(q.upcoming)
+	match := func(queued string) bool { return queued == id }
+	q.requested = slices.DeleteFunc(q.requested, match)
+	q.upcoming = slices.DeleteFunc(q.upcoming, match)
+	return len(q.requested)+len(q.upcoming) != n
+}
+
+// Peek returns the next n clips without playing them,
+// the strategy draws on a copy if it's a StrategyCloner.
+func (q *Queue) Peek(clips PlaylistClips, n int) PlaylistClips {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	cloner, ok := q.strategy.(StrategyCloner)
+	if !ok {
+		q.fill(clips, n-len(q.requested))
+	}
+	byID := q.fill(clips, 0)
+
+	ids := append(slices.Clone(q.requested), q.upcoming...)
+	if ok && len(clips) > 0 {
+		strategy := cloner.Clone()
+		for len(ids) < n {
+			ids = append(ids, strategy.Next(clips).Clip.ID)
+		}
+	}
+
+	var peek PlaylistClips
+	for _, id := range ids {
+		if len(peek) == n {
+			break
+		}
+		peek = append(peek, byID[id])
+	}
+	return peek
+}
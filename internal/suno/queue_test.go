@@ -0,0 +1,120 @@
+package suno
+
+import (
+	"fmt"
+	"testing"
+)
+
+func testClips(n int) PlaylistClips {
+	var clips PlaylistClips
+	for i := range n {
+		clip := &PlaylistClip{RelativeIndex: float64(i)}
+		clip.Clip.ID = fmt.Sprintf("clip-%d", i)
+		clips = append(clips, clip)
+	}
+	return clips
+}
+
+func TestQueueShuffle(t *testing.T) {
+	clips := testClips(10)
+	q := NewQueue(NewStrategy(OrderShuffle), 0, 0)
+
+	var last string
+	for round := range 20 {
+		seen := make(map[string]struct{})
+		for range len(clips) {
+			clip := q.Next(clips)
+			if clip.Clip.ID == last {
+				t.Fatalf("round %d: %s played twice in a row", round, last)
+			}
+			if _, ok := seen[clip.Clip.ID]; ok {
+				t.Fatalf("round %d: %s repeated", round, clip.Clip.ID)
+			}
+			seen[clip.Clip.ID] = struct{}{}
+			last = clip.Clip.ID
+		}
+	}
+}
+
+func TestQueueNoRepeat(t *testing.T) {
+	clips := testClips(5)
+	q := NewQueue(NewStrategy(OrderRandom), 3, 0)
+
+	var played []string
+	for range 200 {
+		clip := q.Next(clips)
+		for i := max(0, len(played)-3); i < len(played); i++ {
+			if played[i] == clip.Clip.ID {
+				t.Fatalf("%s repeated within 3 songs: %v", clip.Clip.ID, played[max(0, len(played)-3):])
+			}
+		}
+		played = append(played, clip.Clip.ID)
+	}
+}
+
+func TestQueuePeek(t *testing.T) {
+	clips := testClips(10)
+	q := NewQueue(NewStrategy(OrderShuffle), 0, 0)
+
+	peek := q.Peek(clips, 5)
+	if len(peek) != 5 {
+		t.Fatalf("got %d clips, expected 5", len(peek))
+	}
+
+	for i := range peek {
+		if clip := q.Next(clips); clip != peek[i] {
+			t.Fatalf("got %s, peeked %s", clip.Clip.ID, peek[i].Clip.ID)
+		}
+	}
+
+	// the removed clips are dropped
+	peek = q.Peek(clips[:1], 5)
+	for i := range peek {
+		if peek[i] != clips[0] {
+			t.Fatalf("got %s, expected %s", peek[i].Clip.ID, clips[0].Clip.ID)
+		}
+	}
+}
+
+func TestQueuePeekClone(t *testing.T) {
+	clips := testClips(3)
+	q := NewQueue(NewStrategy(OrderLeastPlayed), 0, 0)
+
+	// the strategy draws on a copy, peeking again shows the same clips
+	for range 3 {
+		if peek := q.Peek(clips, 3); len(peek) != 3 || peek[0] != clips[0] || peek[2] != clips[2] {
+			t.Fatalf("peeked %v", peek)
+		}
+	}
+	if len(q.upcoming) != 0 {
+		t.Fatalf("%d upcoming clips drawn by peeking", len(q.upcoming))
+	}
+
+	for i := range clips {
+		if clip := q.Next(clips); clip != clips[i] {
+			t.Fatalf("got %s, expected %s", clip.Clip.ID, clips[i].Clip.ID)
+		}
+	}
+}
+
+func TestQueueRequested(t *testing.T) {
+	clips := testClips(10)
+	q := NewQueue(NewStrategy(OrderPlaylist), 0, 0)
+
+	q.Enqueue(clips[7].Clip.ID)
+	q.Enqueue(clips[5].Clip.ID)
+	q.Enqueue(clips[3].Clip.ID)
+
+	if !q.Remove(clips[5].Clip.ID) {
+		t.Fatal("expected the requested clip to be removed")
+	}
+	if q.Remove("not-queued") {
+		t.Fatal("removed a clip not queued")
+	}
+
+	for i, index := range []int{7, 3, 0, 1} {
+		if clip := q.Next(clips); clip != clips[index] {
+			t.Fatalf("%d: got %s, expected %s", i, clip.Clip.ID, clips[index].Clip.ID)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package suno
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path"
+	"sort"
+	"sync"
+	"time"
+)
+
+const registryFile = "playlists.json"
+
+// PlaylistEntry is a playlist from the config, or added at runtime and restored on boot.
+type PlaylistEntry struct {
+	ID      string          `json:"id"`
+	Alias   string          `json:"alias"`
+	Options PlaylistOptions `json:"options"`
+	AddedAt time.Time       `json:"added_at,omitempty"`
+}
+
+type registry struct {
+	mu sync.Mutex
+	p  string
+}
+
+func newRegistry(dir string) *registry {
+	return &registry{p: path.Join(dir, registryFile)}
+}
+
+func (r *registry) load() ([]*PlaylistEntry, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.read()
+}
+
+func (r *registry) read() ([]*PlaylistEntry, error) {
+	b, err := os.ReadFile(r.p)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []*PlaylistEntry
+	err = json.Unmarshal(b, &entries)
+	if err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+func (r *registry) write(entries []*PlaylistEntry) error {
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].AddedAt.Before(entries[j].AddedAt)
+	})
+
+	b, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	err = os.WriteFile(r.p+".tmp", b, 0644)
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(r.p+".tmp", r.p)
+}
+
+func (r *registry) put(entry *PlaylistEntry) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	entries, err := r.read()
+	if err != nil {
+		return err
+	}
+
+	for i := range entries {
+		if entries[i].ID == entry.ID {
+			entries[i] = entry
+			return r.write(entries)
+		}
+	}
+
+	return r.write(append(entries, entry))
+}
+
+func (r *registry) delete(id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	entries, err := r.read()
+	if err != nil {
+		return err
+	}
+
+	for i := range entries {
+		if entries[i].ID == id {
+			return r.write(append(entries[:i], entries[i+1:]...))
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,101 @@
+package suno
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"github.com/hellodword/suno-radio/internal/ogg"
+)
+
+var (
+	// a 20ms CELT fullband frame of silence, as in cmd/issue-opus
+	opusSilence = []byte{252, 255, 254}
+
+	opusSilenceSamples = int64(ogg.OpusPacketSamples(opusSilence))
+)
+
+// the packets per silence page
+const silencePagePackets = 50
+
+// isSilentPacket is the digital silence, the packets of the quiet music are short too.
+func isSilentPacket(packet []byte) bool {
+	return ogg.OpusPacketSilent(packet)
+}
+
+// streamSilence broadcasts the silence pages of the duration after the clip,
+// the MP3 listeners get no gap.
+func (w *Worker) streamSilence(ctx context.Context, clip *PlaylistClip, d time.Duration) error {
+	left := (int64(d)*DefaultSampleRate/int64(time.Second) + opusSilenceSamples - 1) / opusSilenceSamples
+
+	for left > 0 && atomic.LoadInt32(&w.streamCount) > 0 {
+		if atomic.LoadInt32(&w.canceled) != 0 {
+			return context.Canceled
+		}
+
+		select {
+		case <-ctx.Done():
+			return context.Canceled
+		default:
+		}
+
+		n := min(left, silencePagePackets)
+		left -= n
+
+		packets := make([][]byte, n)
+		for i := range packets {
+			packets[i] = opusSilence
+		}
+
+		w.publishOpus(ctx, &oggPage{clip: clip, packets: packets}, n*opusSilenceSamples)
+	}
+
+	return nil
+}
+
+// silenceTrimmer drops the leading silent packets of a clip, and holds the silent ones back
+// until the clip goes on, so the trailing ones are dropped at the end.
+type silenceTrimmer struct {
+	started bool
+	// the samples of the leading silence
+	skipped     int64
+	held        [][]byte
+	heldSamples int64
+}
+
+// trim returns the packets to publish and their samples, nothing while the packets are silent.
+func (t *silenceTrimmer) trim(packets [][]byte, pcmLen int64) ([][]byte, int64) {
+	if !t.started {
+		for len(packets) > 0 && isSilentPacket(packets[0]) {
+			samples := int64(ogg.OpusPacketSamples(packets[0]))
+			pcmLen -= samples
+			t.skipped += samples
+			packets = packets[1:]
+		}
+		if len(packets) == 0 {
+			return nil, 0
+		}
+		t.started = true
+	}
+
+	i := len(packets)
+	var tailSamples int64
+	for i > 0 && isSilentPacket(packets[i-1]) {
+		i--
+		tailSamples += int64(ogg.OpusPacketSamples(packets[i]))
+	}
+
+	if i == 0 {
+		t.held = append(t.held, packets...)
+		t.heldSamples += pcmLen
+		return nil, 0
+	}
+
+	out := append(t.held, packets[:i]...)
+	outSamples := t.heldSamples + pcmLen - tailSamples
+
+	t.held = append([][]byte(nil), packets[i:]...)
+	t.heldSamples = tailSamples
+
+	return out, max(0, outSamples)
+}
@@ -0,0 +1,56 @@
+package suno
+
+import (
+	"testing"
+)
+
+func TestSilenceTrimmer(t *testing.T) {
+	loud := []byte{252, 1, 2, 3, 4}
+	quiet := opusSilence
+
+	page := func(packets ...[]byte) ([][]byte, int64) {
+		return packets, int64(len(packets)) * opusSilenceSamples
+	}
+
+	var tr silenceTrimmer
+
+	for i, c := range []struct {
+		packets [][]byte
+		want    int
+	}{
+		// the leading silence
+		{[][]byte{quiet, quiet}, 0},
+		{[][]byte{quiet, loud, quiet}, 1},
+		// the silence in the middle of the clip is kept
+		{[][]byte{quiet, quiet}, 0},
+		{[][]byte{loud}, 4},
+		// the trailing silence is never released
+		{[][]byte{loud, quiet}, 1},
+		{[][]byte{quiet, quiet, quiet}, 0},
+	} {
+		packets, pcmLen := tr.trim(page(c.packets...))
+		if len(packets) != c.want {
+			t.Fatalf("page %d: %d packets, want %d", i, len(packets), c.want)
+		}
+		if want := int64(c.want) * opusSilenceSamples; pcmLen != want {
+			t.Fatalf("page %d: %d samples, want %d", i, pcmLen, want)
+		}
+	}
+
+	if len(tr.held) != 4 {
+		t.Fatalf("%d packets held, want 4", len(tr.held))
+	}
+}
+
+func TestSilencePacket(t *testing.T) {
+	if opusSilenceSamples != 960 {
+		t.Fatalf("silence of %d samples, want 960", opusSilenceSamples)
+	}
+	if !isSilentPacket(opusSilence) {
+		t.Fatal("the silence packet is not silent")
+	}
+	// as short as the silence
+	if isSilentPacket([]byte{252, 1, 2}) {
+		t.Fatal("a short packet is silent")
+	}
+}
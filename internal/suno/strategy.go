@@ -0,0 +1,265 @@
+package suno
+
+import (
+	"maps"
+	"math/rand/v2"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	OrderShuffle     = "shuffle"
+	OrderRandom      = "random"
+	OrderPlaylist    = "playlist"
+	OrderReverse     = "reverse"
+	OrderUpvote      = "upvote"
+	OrderNewest      = "newest"
+	OrderLeastPlayed = "least-played"
+)
+
+// Strategy picks the next clip, it's stateful and owned by one Queue.
+type Strategy interface {
+	// Next picks one of clips, clips is never empty and sorted by the relative index.
+	Next(clips PlaylistClips) *PlaylistClip
+}
+
+// StrategyCloner is implemented by the strategies Queue.Peek can draw from without changing what plays,
+// the others are drawn from, and the peeked clips are played.
+type StrategyCloner interface {
+	Clone() Strategy
+}
+
+// playsSeeder is implemented by the strategies which take the play counts of the previous runs.
+type playsSeeder interface {
+	seedPlays(plays map[string]int64)
+}
+
+var (
+	strategiesMu sync.RWMutex
+	strategies   = map[string]func() Strategy{
+		OrderShuffle: func() Strategy {
+			return &shuffleStrategy{rnd: newRand(), played: make(map[string]struct{})}
+		},
+		OrderRandom: func() Strategy {
+			return &randomStrategy{rnd: newRand()}
+		},
+		OrderPlaylist: func() Strategy {
+			return &cycleStrategy{less: func(a, b *PlaylistClip) bool { return a.RelativeIndex < b.RelativeIndex }}
+		},
+		OrderReverse: func() Strategy {
+			return &cycleStrategy{less: func(a, b *PlaylistClip) bool { return a.RelativeIndex > b.RelativeIndex }}
+		},
+		OrderUpvote: func() Strategy {
+			return &upvoteStrategy{rnd: newRand()}
+		},
+		OrderNewest: func() Strategy {
+			return &cycleStrategy{less: func(a, b *PlaylistClip) bool { return a.Clip.CreatedAt.After(b.Clip.CreatedAt) }}
+		},
+		OrderLeastPlayed: func() Strategy {
+			return &leastPlayedStrategy{plays: make(map[string]int64), drawn: make(map[string]int)}
+		},
+	}
+)
+
+// pcgRand can be cloned with its state, so the clone draws the same numbers.
+type pcgRand struct {
+	*rand.Rand
+	src *rand.PCG
+}
+
+func newRand() pcgRand {
+	src := rand.NewPCG(uint64(time.Now().UnixNano()), rand.Uint64())
+	return pcgRand{Rand: rand.New(src), src: src}
+}
+
+func (r pcgRand) clone() pcgRand {
+	src := *r.src
+	return pcgRand{Rand: rand.New(&src), src: &src}
+}
+
+// RegisterStrategy makes the order available to the config and the api,
+// the factory is called for every Worker.
+func RegisterStrategy(order string, factory func() Strategy) {
+	strategiesMu.Lock()
+	defer strategiesMu.Unlock()
+	strategies[order] = factory
+}
+
+// NewStrategy falls back to OrderShuffle for the unknown orders.
+func NewStrategy(order string) Strategy {
+	strategiesMu.RLock()
+	defer strategiesMu.RUnlock()
+
+	factory, ok := strategies[order]
+	if !ok {
+		factory = strategies[OrderShuffle]
+	}
+	return factory()
+}
+
+func ValidOrder(order string) bool {
+	strategiesMu.RLock()
+	defer strategiesMu.RUnlock()
+
+	_, ok := strategies[order]
+	return ok
+}
+
+func Orders() []string {
+	strategiesMu.RLock()
+	defer strategiesMu.RUnlock()
+
+	var orders []string
+	for order := range strategies {
+		orders = append(orders, order)
+	}
+	sort.Strings(orders)
+	return orders
+}
+
+// randomStrategy is the uniform random, the same clip may play twice in a row.
+type randomStrategy struct {
+	rnd pcgRand
+}
+
+func (s *randomStrategy) Next(clips PlaylistClips) *PlaylistClip {
+	return clips[s.rnd.IntN(len(clips))]
+}
+
+func (s *randomStrategy) Clone() Strategy {
+	return &randomStrategy{rnd: s.rnd.clone()}
+}
+
+// cycleStrategy walks the clips sorted by less and wraps,
+// the clips added meanwhile are picked up in place.
+type cycleStrategy struct {
+	less func(a, b *PlaylistClip) bool
+	last *PlaylistClip
+}
+
+// before is less with the ties broken by the id, so the clips which tie are not skipped.
+func (s *cycleStrategy) before(a, b *PlaylistClip) bool {
+	if s.less(a, b) {
+		return true
+	}
+	if s.less(b, a) {
+		return false
+	}
+	return a.Clip.ID < b.Clip.ID
+}
+
+func (s *cycleStrategy) Next(clips PlaylistClips) *PlaylistClip {
+	sorted := slices.Clone(clips)
+	sort.Slice(sorted, func(i, j int) bool { return s.before(sorted[i], sorted[j]) })
+
+	next := sorted[0]
+	if s.last != nil {
+		for _, clip := range sorted {
+			if s.before(s.last, clip) {
+				next = clip
+				break
+			}
+		}
+	}
+	s.last = next
+	return next
+}
+
+func (s *cycleStrategy) Clone() Strategy {
+	clone := *s
+	return &clone
+}
+
+// shuffleStrategy is a shuffle bag, every clip plays once per round.
+type shuffleStrategy struct {
+	rnd pcgRand
+	// played in the current round
+	played map[string]struct{}
+	last   string
+}
+
+func (s *shuffleStrategy) Next(clips PlaylistClips) *PlaylistClip {
+	var bag PlaylistClips
+	for _, clip := range clips {
+		if _, ok := s.played[clip.Clip.ID]; !ok {
+			bag = append(bag, clip)
+		}
+	}
+
+	if len(bag) == 0 {
+		clear(s.played)
+		for _, clip := range clips {
+			// no repeat across the rounds
+			if clip.Clip.ID != s.last || len(clips) == 1 {
+				bag = append(bag, clip)
+			}
+		}
+	}
+
+	next := bag[s.rnd.IntN(len(bag))]
+	s.played[next.Clip.ID] = struct{}{}
+	s.last = next.Clip.ID
+	return next
+}
+
+func (s *shuffleStrategy) Clone() Strategy {
+	return &shuffleStrategy{rnd: s.rnd.clone(), played: maps.Clone(s.played), last: s.last}
+}
+
+// upvoteStrategy is random weighted by the upvotes, every clip has a chance.
+type upvoteStrategy struct {
+	rnd pcgRand
+}
+
+func (s *upvoteStrategy) Next(clips PlaylistClips) *PlaylistClip {
+	var total int64
+	for _, clip := range clips {
+		total += max(clip.Clip.UpvoteCount, 0) + 1
+	}
+
+	n := s.rnd.Int64N(total)
+	for _, clip := range clips {
+		n -= max(clip.Clip.UpvoteCount, 0) + 1
+		if n < 0 {
+			return clip
+		}
+	}
+	return clips[len(clips)-1]
+}
+
+func (s *upvoteStrategy) Clone() Strategy {
+	return &upvoteStrategy{rnd: s.rnd.clone()}
+}
+
+// leastPlayedStrategy picks the clip played the least, the one drawn longest ago of them.
+type leastPlayedStrategy struct {
+	// the plays of the previous runs and the draws since
+	plays map[string]int64
+	drawn map[string]int
+	seq   int
+}
+
+func (s *leastPlayedStrategy) Next(clips PlaylistClips) *PlaylistClip {
+	next := clips[0]
+	for _, clip := range clips[1:] {
+		plays, nextPlays := s.plays[clip.Clip.ID], s.plays[next.Clip.ID]
+		if plays < nextPlays || plays == nextPlays && s.drawn[clip.Clip.ID] < s.drawn[next.Clip.ID] {
+			next = clip
+		}
+	}
+
+	s.plays[next.Clip.ID]++
+	s.seq++
+	s.drawn[next.Clip.ID] = s.seq
+	return next
+}
+
+func (s *leastPlayedStrategy) Clone() Strategy {
+	return &leastPlayedStrategy{plays: maps.Clone(s.plays), drawn: maps.Clone(s.drawn), seq: s.seq}
+}
+
+func (s *leastPlayedStrategy) seedPlays(plays map[string]int64) {
+	maps.Copy(s.plays, plays)
+}
@@ -0,0 +1,100 @@
+package suno
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCycleStrategies(t *testing.T) {
+	clips := testClips(4)
+	for i, clip := range clips {
+		// the last one is the newest
+		clip.Clip.CreatedAt = time.Unix(int64(i), 0)
+	}
+
+	for order, expect := range map[string][]int{
+		OrderPlaylist: {0, 1, 2, 3, 0, 1},
+		OrderReverse:  {3, 2, 1, 0, 3, 2},
+		OrderNewest:   {3, 2, 1, 0, 3, 2},
+	} {
+		s := NewStrategy(order)
+		for i, index := range expect {
+			if clip := s.Next(clips); clip != clips[index] {
+				t.Fatalf("%s %d: got %s, expected %s", order, i, clip.Clip.ID, clips[index].Clip.ID)
+			}
+		}
+	}
+}
+
+func TestCycleStrategyTies(t *testing.T) {
+	// created at the same time, the id breaks the tie
+	clips := testClips(3)
+
+	s := NewStrategy(OrderNewest)
+	for i := range 6 {
+		if clip := s.Next(clips); clip != clips[i%3] {
+			t.Fatalf("%d: got %s, expected %s", i, clip.Clip.ID, clips[i%3].Clip.ID)
+		}
+	}
+}
+
+func TestLeastPlayedStrategy(t *testing.T) {
+	clips := testClips(3)
+	s := NewStrategy(OrderLeastPlayed)
+
+	for i := range 6 {
+		if clip := s.Next(clips); clip != clips[i%3] {
+			t.Fatalf("%d: got %s, expected %s", i, clip.Clip.ID, clips[i%3].Clip.ID)
+		}
+	}
+
+	// a new clip goes first
+	clips = append(clips, testClips(4)[3])
+	if clip := s.Next(clips); clip != clips[3] {
+		t.Fatalf("got %s, expected %s", clip.Clip.ID, clips[3].Clip.ID)
+	}
+
+	// the plays of the previous runs count
+	s = NewStrategy(OrderLeastPlayed)
+	s.(playsSeeder).seedPlays(map[string]int64{"clip-0": 2, "clip-1": 1})
+	for i, index := range []int{2, 3, 1, 2} {
+		if clip := s.Next(clips); clip != clips[index] {
+			t.Fatalf("%d: got %s, expected %s", i, clip.Clip.ID, clips[index].Clip.ID)
+		}
+	}
+}
+
+func TestUpvoteStrategy(t *testing.T) {
+	clips := testClips(2)
+	clips[1].Clip.UpvoteCount = 99
+
+	s := NewStrategy(OrderUpvote)
+	var count int
+	for range 1000 {
+		if s.Next(clips) == clips[1] {
+			count++
+		}
+	}
+
+	// 99%
+	if count < 950 {
+		t.Fatalf("the upvoted clip got %d/1000", count)
+	}
+}
+
+type firstStrategy struct{}
+
+func (firstStrategy) Next(clips PlaylistClips) *PlaylistClip { return clips[0] }
+
+func TestRegisterStrategy(t *testing.T) {
+	RegisterStrategy("first", func() Strategy { return firstStrategy{} })
+
+	if !ValidOrder("first") {
+		t.Fatal("first is not registered")
+	}
+
+	clips := testClips(3)
+	if clip := NewStrategy("first").Next(clips); clip != clips[0] {
+		t.Fatalf("got %s, expected %s", clip.Clip.ID, clips[0].Clip.ID)
+	}
+}
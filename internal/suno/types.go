@@ -1,7 +1,9 @@
 package suno
 
 import (
+	"fmt"
 	"net/http"
+	"strings"
 	"time"
 )
 
@@ -11,43 +13,104 @@ type PlaylistClip struct {
 	Clip struct {
 		ID string `json:"id,omitempty"`
 		// VideoURL          string `json:"video_url,omitempty"`
-		AudioURL string `json:"audio_url,omitempty"`
-		// ImageURL          string `json:"image_url,omitempty"`
-		// ImageLargeURL     string `json:"image_large_url,omitempty"`
-		// MajorModelVersion string `json:"major_model_version,omitempty"`
-		// ModelName string `json:"model_name,omitempty"`
-		// Metadata struct {
-		// 	// Tags   string `json:"tags,omitempty"`
-		// 	// Prompt string `json:"prompt,omitempty"`
-		// 	// GptDescriptionPrompt any    `json:"gpt_description_prompt,omitempty"`
-		// 	// AudioPromptID any `json:"audio_prompt_id,omitempty"`
-		// 	// History       any `json:"history,omitempty"`
-		// 	ConcatHistory []struct {
-		// 		ID         string   `json:"id,omitempty"`
-		// 		ContinueAt *float64 `json:"continue_at,omitempty"`
-		// 	} `json:"concat_history,omitempty"`
-		// 	Type     string  `json:"type,omitempty"`
-		// 	Duration float64 `json:"duration,omitempty"`
-		// 	// RefundCredits any     `json:"refund_credits,omitempty"`
-		// 	// Stream       any `json:"stream,omitempty"`
-		// 	// ErrorType    any `json:"error_type,omitempty"`
-		// 	// ErrorMessage any `json:"error_message,omitempty"`
-		// } `json:"metadata,omitempty"`
+		AudioURL          string `json:"audio_url,omitempty"`
+		ImageURL          string `json:"image_url,omitempty"`
+		ImageLargeURL     string `json:"image_large_url,omitempty"`
+		MajorModelVersion string `json:"major_model_version,omitempty"`
+		ModelName         string `json:"model_name,omitempty"`
+		Metadata          struct {
+			Tags string `json:"tags,omitempty"`
+			// lyrics
+			Prompt string `json:"prompt,omitempty"`
+			// GptDescriptionPrompt any    `json:"gpt_description_prompt,omitempty"`
+			// AudioPromptID any `json:"audio_prompt_id,omitempty"`
+			// History       any `json:"history,omitempty"`
+			// ConcatHistory []struct {
+			// 	ID         string   `json:"id,omitempty"`
+			// 	ContinueAt *float64 `json:"continue_at,omitempty"`
+			// } `json:"concat_history,omitempty"`
+			Type     string  `json:"type,omitempty"`
+			Duration float64 `json:"duration,omitempty"`
+			// RefundCredits any     `json:"refund_credits,omitempty"`
+			// Stream       any `json:"stream,omitempty"`
+			// ErrorType    any `json:"error_type,omitempty"`
+			// ErrorMessage any `json:"error_message,omitempty"`
+		} `json:"metadata,omitempty"`
 		// IsLiked   bool   `json:"is_liked,omitempty"`
-		// UserID    string `json:"user_id,omitempty"`
-		// IsTrashed bool   `json:"is_trashed,omitempty"`
+		UserID      string `json:"user_id,omitempty"`
+		DisplayName string `json:"display_name,omitempty"`
+		Handle      string `json:"handle,omitempty"`
+		IsTrashed   bool   `json:"is_trashed,omitempty"`
 		// Reaction    any       `json:"reaction,omitempty"`
-		CreatedAt time.Time `json:"created_at,omitempty"`
-		Status    string    `json:"status,omitempty"`
-		Title     string    `json:"title,omitempty"`
-		// PlayCount   int64     `json:"play_count,omitempty"`
-		UpvoteCount int64 `json:"upvote_count,omitempty"`
-		// IsPublic    bool  `json:"is_public,omitempty"`
+		CreatedAt   time.Time `json:"created_at,omitempty"`
+		Status      string    `json:"status,omitempty"`
+		Title       string    `json:"title,omitempty"`
+		PlayCount   int64     `json:"play_count,omitempty"`
+		UpvoteCount int64     `json:"upvote_count,omitempty"`
+		IsPublic    bool      `json:"is_public,omitempty"`
 	} `json:"clip,omitempty"`
 	RelativeIndex float64   `json:"relative_index,omitempty"`
 	UpdatedAt     time.Time `json:"updated_at,omitempty"`
 }
 
+// Playable reports whether the clip is worth downloading and scheduling.
+func (clip *PlaylistClip) Playable() bool {
+	if clip.Clip.ID == "" || clip.Clip.AudioURL == "" || clip.Clip.IsTrashed {
+		return false
+	}
+	return clip.Clip.Status == "" || clip.Clip.Status == "complete"
+}
+
+// Tags splits the comma separated style tags.
+func (clip *PlaylistClip) Tags() []string {
+	var tags []string
+	for _, tag := range strings.Split(clip.Clip.Metadata.Tags, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+func (clip *PlaylistClip) URL() string {
+	return fmt.Sprintf("https://suno.com/song/%s", clip.Clip.ID)
+}
+
+// Info is what the frontends need for the currently playing clip.
+func (clip *PlaylistClip) Info() map[string]any {
+	m := map[string]any{
+		"id":           clip.Clip.ID,
+		"title":        clip.Clip.Title,
+		"upvote_count": clip.Clip.UpvoteCount,
+		"play_count":   clip.Clip.PlayCount,
+		"url":          clip.URL(),
+	}
+
+	for k, v := range map[string]string{
+		"image_url":       clip.Clip.ImageURL,
+		"image_large_url": clip.Clip.ImageLargeURL,
+		"model_name":      clip.Clip.ModelName,
+		"lyrics":          clip.Clip.Metadata.Prompt,
+		"display_name":    clip.Clip.DisplayName,
+		"handle":          clip.Clip.Handle,
+	} {
+		if v != "" {
+			m[k] = v
+		}
+	}
+
+	if tags := clip.Tags(); len(tags) > 0 {
+		m["tags"] = tags
+	}
+
+	if clip.Clip.Metadata.Duration > 0 {
+		m["duration"] = clip.Clip.Metadata.Duration
+	}
+
+	return m
+}
+
 type PlaylistClips []*PlaylistClip
 
 func (s PlaylistClips) Len() int {
@@ -63,17 +126,21 @@ func (s PlaylistClips) Swap(i, j int) {
 }
 
 type PlaylistInfo struct {
-	ID          string `json:"id"`
-	Name        string `json:"name,omitempty"`
-	Description string `json:"description,omitempty"`
+	ID              string `json:"id"`
+	Name            string `json:"name,omitempty"`
+	Description     string `json:"description,omitempty"`
+	NumTotalResults int    `json:"num_total_results,omitempty"`
+}
+
+func (info PlaylistInfo) URL() string {
+	return fmt.Sprintf("https://suno.com/playlist/%s", info.ID)
 }
 
 type Playlist struct {
 	PlaylistInfo
 	PlaylistClips PlaylistClips `json:"playlist_clips,omitempty"`
 	// ImageURL        string `json:"image_url,omitempty"`
-	// NumTotalResults int    `json:"num_total_results,omitempty"`
-	// CurrentPage     int    `json:"current_page,omitempty"`
+	CurrentPage int `json:"current_page,omitempty"`
 	// IsOwned         bool   `json:"is_owned,omitempty"`
 	// IsTrashed       bool   `json:"is_trashed,omitempty"`
 	// IsPublic        bool   `json:"is_public,omitempty"`
@@ -0,0 +1,30 @@
+package suno
+
+import "testing"
+
+func TestPlayable(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		edit     func(clip *PlaylistClip)
+		playable bool
+	}{
+		{"complete", func(clip *PlaylistClip) { clip.Clip.Status = "complete" }, true},
+		{"no status", func(clip *PlaylistClip) {}, true},
+		{"streaming", func(clip *PlaylistClip) { clip.Clip.Status = "streaming" }, false},
+		{"error", func(clip *PlaylistClip) { clip.Clip.Status = "error" }, false},
+		{"trashed", func(clip *PlaylistClip) { clip.Clip.IsTrashed = true }, false},
+		{"no audio", func(clip *PlaylistClip) { clip.Clip.AudioURL = "" }, false},
+		{"no id", func(clip *PlaylistClip) { clip.Clip.ID = "" }, false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			clip := &PlaylistClip{}
+			clip.Clip.ID = "clip"
+			clip.Clip.AudioURL = "https://cdn1.suno.ai/clip.mp3"
+			tc.edit(clip)
+
+			if clip.Playable() != tc.playable {
+				t.Fatalf("Playable() = %v, expected %v", !tc.playable, tc.playable)
+			}
+		})
+	}
+}
@@ -0,0 +1,141 @@
+package suno
+
+import (
+	"errors"
+	"math"
+	"sync"
+	"sync/atomic"
+)
+
+var (
+	ErrVotingDisabled  = errors.New("voting disabled")
+	ErrSessionNotFound = errors.New("session not found")
+)
+
+// VoteResult is the tally of the skip votes for the current clip.
+type VoteResult struct {
+	ClipID  string `json:"clip_id"`
+	Votes   int    `json:"votes"`
+	Needed  int    `json:"needed"`
+	Skipped bool   `json:"skipped"`
+}
+
+// skipVotes tracks the connected sessions and their votes for the current clip.
+type skipVotes struct {
+	mu       sync.Mutex
+	clipID   string
+	sessions map[string]int
+	ballots  map[string]struct{}
+}
+
+func newSkipVotes() *skipVotes {
+	return &skipVotes{
+		sessions: make(map[string]int),
+		ballots:  make(map[string]struct{}),
+	}
+}
+
+// join counts the session, a session may hold several streams,
+// the hls segmenter is not a listener who can vote.
+func (v *skipVotes) join(session string) {
+	if session == hlsSession {
+		return
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	v.sessions[session]++
+}
+
+// leave drops the vote once the last stream of the session is gone.
+func (v *skipVotes) leave(session string) {
+	if session == hlsSession {
+		return
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	v.sessions[session]--
+	if v.sessions[session] <= 0 {
+		delete(v.sessions, session)
+		delete(v.ballots, session)
+	}
+}
+
+// voters is the number of the sessions which can vote.
+func (v *skipVotes) voters() int32 {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	return int32(len(v.sessions))
+}
+
+// reset clears the votes when the clip changes.
+func (v *skipVotes) reset(clipID string) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	v.clipID = clipID
+	clear(v.ballots)
+}
+
+// vote records the ballot of the session for the clip and returns the votes.
+func (v *skipVotes) vote(session, clipID string) (int, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if _, ok := v.sessions[session]; !ok {
+		return 0, ErrSessionNotFound
+	}
+
+	if v.clipID != clipID {
+		v.clipID = clipID
+		clear(v.ballots)
+	}
+
+	v.ballots[session] = struct{}{}
+	return len(v.ballots), nil
+}
+
+// votesNeeded is the ceil of the ratio of the voters, at least 1.
+func votesNeeded(ratio float64, voters int32) int {
+	return max(1, int(math.Ceil(ratio*float64(voters))))
+}
+
+// Vote casts the skip vote of a streaming session for the current clip,
+// the clip is skipped once the SkipVoteRatio of the sessions agree.
+func (w *Worker) Vote(session string) (*VoteResult, error) {
+	opts := w.Options()
+	if opts.SkipVoteRatio <= 0 {
+		return nil, ErrVotingDisabled
+	}
+
+	clip, ok := w.listeningCLipID.Load().(*PlaylistClip)
+	if !ok || atomic.LoadInt32(&w.playing) == 0 {
+		return nil, ErrNotPlaying
+	}
+
+	votes, err := w.votes.vote(session, clip.Clip.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &VoteResult{
+		ClipID: clip.Clip.ID,
+		Votes:  votes,
+		Needed: votesNeeded(opts.SkipVoteRatio, w.votes.voters()),
+	}
+
+	if result.Votes >= result.Needed {
+		w.logger.Info("skipped by votes", "clip", clip.Clip.ID, "votes", result.Votes, "needed", result.Needed)
+		if err = w.Skip(); err != nil {
+			return nil, err
+		}
+		w.votes.reset(clip.Clip.ID)
+		result.Skipped = true
+	}
+
+	return result, nil
+}
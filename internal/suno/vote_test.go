@@ -0,0 +1,96 @@
+package suno
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestSkipVotes(t *testing.T) {
+	v := newSkipVotes()
+	v.reset("clip-0")
+
+	_, err := v.vote("a", "clip-0")
+	if !errors.Is(err, ErrSessionNotFound) {
+		t.Fatalf("vote without a stream: %v", err)
+	}
+
+	v.join("a")
+	v.join("a")
+	v.join("b")
+
+	for range 2 {
+		votes, err := v.vote("a", "clip-0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if votes != 1 {
+			t.Fatalf("votes %d, want 1", votes)
+		}
+	}
+
+	votes, _ := v.vote("b", "clip-0")
+	if votes != 2 {
+		t.Fatalf("votes %d, want 2", votes)
+	}
+
+	// one of the two streams of a is still connected
+	v.leave("a")
+	votes, _ = v.vote("b", "clip-0")
+	if votes != 2 {
+		t.Fatalf("votes %d after a stream left, want 2", votes)
+	}
+
+	v.leave("a")
+	votes, _ = v.vote("b", "clip-0")
+	if votes != 1 {
+		t.Fatalf("votes %d after a session left, want 1", votes)
+	}
+
+	v.reset("clip-1")
+	votes, _ = v.vote("b", "clip-1")
+	if votes != 1 {
+		t.Fatalf("votes %d after the clip changed, want 1", votes)
+	}
+}
+
+func TestVotesNeeded(t *testing.T) {
+	for _, c := range []struct {
+		ratio     float64
+		listeners int32
+		needed    int
+	}{
+		{0.5, 0, 1},
+		{0.5, 1, 1},
+		{0.5, 3, 2},
+		{0.5, 4, 2},
+		{1, 5, 5},
+		{0.3, 10, 3},
+	} {
+		if needed := votesNeeded(c.ratio, c.listeners); needed != c.needed {
+			t.Errorf("votesNeeded(%v, %d) = %d, want %d", c.ratio, c.listeners, needed, c.needed)
+		}
+	}
+}
+
+func TestSkipVoters(t *testing.T) {
+	v := newSkipVotes()
+
+	// the streams of a session and the hls segmenter don't count
+	v.join("a")
+	v.join("a")
+	v.join("b")
+	v.join(hlsSession)
+	if voters := v.voters(); voters != 2 {
+		t.Fatalf("voters %d, want 2", voters)
+	}
+
+	if needed := votesNeeded(1, v.voters()); needed != 2 {
+		t.Fatalf("needed %d, want 2", needed)
+	}
+
+	v.leave(hlsSession)
+	v.leave("b")
+	if voters := v.voters(); voters != 1 {
+		t.Fatalf("voters %d after b left, want 1", voters)
+	}
+}
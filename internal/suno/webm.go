@@ -0,0 +1,78 @@
+package suno
+
+import (
+	"context"
+	"io"
+	"sync/atomic"
+
+	"github.com/hellodword/suno-radio/internal/ogg"
+	"github.com/hellodword/suno-radio/internal/webm"
+)
+
+// StreamWebM writes the same Opus packets as Stream in a live WebM until the ctx is done,
+// for the MediaSource Extensions.
+func (w *Worker) StreamWebM(session string, ctx context.Context, writer io.Writer) error {
+	opts := w.Options()
+
+	leave, err := w.join(session, opts)
+	if err != nil {
+		return err
+	}
+	defer leave()
+
+	burst, listener, err := w.subscribe()
+	if err != nil {
+		return err
+	}
+	defer listener.Close()
+
+	encoder := webm.NewEncoder(writer, ProjectName)
+	err = encoder.EncodeHeader(&ogg.IDHeader{
+		Version:            1,
+		OutputChannelCount: DefaultChannels,
+		PreSkip:            0,
+		InputSampleRate:    DefaultSampleRate,
+		OutputGainQ7_8:     opts.GainQ7_8(),
+	})
+	if err != nil {
+		return err
+	}
+
+	clipWriter, _ := writer.(ClipWriter)
+	var lastClip *PlaylistClip
+
+	write := func(page *oggPage) error {
+		if clipWriter != nil && page.clip != lastClip {
+			clipWriter.SetClip(page.clip)
+			lastClip = page.clip
+		}
+		return encoder.Encode(page.granule, page.packets)
+	}
+
+	for _, page := range burst {
+		err := write(page)
+		if err != nil {
+			return err
+		}
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return context.Canceled
+		case page := <-listener.Ch():
+			if page == nil {
+				return context.Canceled
+			}
+
+			if atomic.LoadInt32(&w.canceled) != 0 {
+				return context.Canceled
+			}
+
+			err := write(page)
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
@@ -2,13 +2,15 @@ package suno
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"log/slog"
-	"math/rand"
 	"os"
 	"path"
+	"sort"
+	"strconv"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -19,12 +21,15 @@ import (
 )
 
 type Worker struct {
-	id       string
-	alias    string
-	playlist *Playlist
+	id string
+	// changed by reloading the config
+	alias atomic.Pointer[string]
+	// replaced by the refresh while the handlers read it
+	playlist atomic.Pointer[Playlist]
 
-	dir      string
-	interval time.Duration
+	client *Client
+	dir    string
+	opts   atomic.Pointer[PlaylistOptions]
 
 	wg     sync.WaitGroup
 	logger *slog.Logger
@@ -32,42 +37,241 @@ type Worker struct {
 	convertedClips sync.Map
 
 	broadcaster *broadcast.Relay[*oggPage]
+	events      *broadcast.Relay[*Event]
+	// follows the ogg pages with the frames of the original MP3
+	mp3Broadcaster *broadcast.Relay[*mp3Chunk]
+	hls            *hlsSegmenter
+	queue          *Queue
 
 	granule   int64
 	beginTime time.Time
+	fade      crossfader
+	// the last pages published, for the new listeners
+	burst burstBuffer
+	// the mp3toogg.Loudness of the clips
+	loudness sync.Map
 
 	streamCount int32
+	votes       *skipVotes
+	history     *history
 
 	listeningCLipID atomic.Value
 
+	playing int32
+	skip    int32
+
+	// held while canceled is set, so no listener or goroutine is added to a closed worker
+	closeMu  sync.Mutex
 	canceled int32
 }
 
-func NewWorker(ctx context.Context, logger *slog.Logger, id, alias string, interval time.Duration, dir string) (*Worker, error) {
+const (
+	VisibilityPublic   = "public"
+	VisibilityUnlisted = "unlisted"
+)
+
+var (
+	ErrTooManyListeners = errors.New("too many listeners")
+	ErrClipNotFound     = errors.New("clip not found")
+	ErrNotPlaying       = errors.New("not playing")
+	ErrWorkerClosed     = errors.New("worker closed")
+)
+
+// PlaylistOptions overrides the pool defaults per playlist, zero values mean the default.
+type PlaylistOptions struct {
+	Interval time.Duration `json:"interval,omitempty"`
+	// negative means unlimited
+	MaxClips int `json:"max_clips,omitempty"`
+	// one of the Orders(), OrderShuffle by default
+	Order string `json:"order,omitempty"`
+	// a clip is not repeated within the songs or the duration if possible
+	NoRepeatSongs  int           `json:"no_repeat_songs,omitempty"`
+	NoRepeatWithin time.Duration `json:"no_repeat_within,omitempty"`
+	// in dB
+	Gain float64 `json:"gain,omitempty"`
+	// 0 means unlimited
+	MaxListeners int `json:"max_listeners,omitempty"`
+	// VisibilityPublic or VisibilityUnlisted
+	Visibility string `json:"visibility,omitempty"`
+	// every clip starts a new logical ogg stream with its own OpusTags, PreSkip and end trim,
+	// without it the encoder delay and padding of every clip are played
+	Chained bool `json:"chained,omitempty"`
+	// the silence between the clips
+	Gap time.Duration `json:"gap,omitempty"`
+	// the integrated loudness in LUFS the clips are normalized to in the chained stream, 0 disables it
+	TargetLUFS float64 `json:"target_lufs,omitempty"`
+	// the overlap of the clips, only with the opus build tag
+	Crossfade time.Duration `json:"crossfade,omitempty"`
+	// drop the leading and trailing silence of the clips
+	TrimSilence bool `json:"trim_silence,omitempty"`
+	// the fraction of the listeners needed to skip a clip, 0 disables the voting
+	SkipVoteRatio float64 `json:"skip_vote_ratio,omitempty"`
+}
+
+// GainQ7_8 is the Gain for the OpusHead.
+func (opts PlaylistOptions) GainQ7_8() int16 {
+	return gainQ7_8(opts.Gain)
+}
+
+func NewWorker(ctx context.Context, logger *slog.Logger, client *Client, id, alias string, opts PlaylistOptions, dir string) (*Worker, error) {
 	var err error
 
-	w := &Worker{id: id, alias: alias, interval: interval, dir: dir, logger: logger,
-		broadcaster: broadcast.NewRelay[*oggPage](),
+	w := &Worker{id: id, client: client, dir: dir, logger: logger,
+		broadcaster:    broadcast.NewRelay[*oggPage](),
+		mp3Broadcaster: broadcast.NewRelay[*mp3Chunk](),
+		hls:            newHLSSegmenter(),
+		events:         broadcast.NewRelay[*Event](),
+		votes:          newSkipVotes(),
+		history:        newHistory(dir),
+	}
+	w.alias.Store(&alias)
+	w.opts.Store(&opts)
+	err = w.history.load()
+	if err != nil {
+		w.logger.WarnContext(ctx, "load history", "err", err)
 	}
 
+	w.queue = NewQueue(w.newStrategy(opts.Order), opts.NoRepeatSongs, opts.NoRepeatWithin)
+
 	w.logger.InfoContext(ctx, "fetching playlist")
-	// TODO pagination
-	w.playlist, err = GetPlaylist(ctx, id, 1)
+	playlist, err := w.client.GetPlaylistAll(ctx, id, opts.MaxClips)
 	if err != nil {
 		w.logger.ErrorContext(ctx, "fetch playlist", "err", err)
-		return nil, err
+
+		// play the cached clips while suno is unreachable
+		playlist, errLoad := w.loadPlaylist()
+		if errLoad != nil {
+			return nil, err
+		}
+		w.playlist.Store(playlist)
+		w.logger.WarnContext(ctx, "loaded cached playlist", "clips", len(playlist.PlaylistClips))
+		return w, nil
+	}
+	w.playlist.Store(playlist)
+	w.logger.InfoContext(ctx, "fetched playlist", "clips", len(playlist.PlaylistClips), "total", playlist.NumTotalResults)
+
+	err = w.savePlaylist(playlist)
+	if err != nil {
+		w.logger.WarnContext(ctx, "save playlist", "err", err)
 	}
-	w.logger.InfoContext(ctx, "fetched playlist")
 
 	return w, nil
 }
 
+const playlistFile = "playlist.json"
+
+func (w *Worker) savePlaylist(playlist *Playlist) error {
+	b, err := json.Marshal(playlist)
+	if err != nil {
+		return err
+	}
+
+	p := path.Join(w.dir, playlistFile)
+	err = os.WriteFile(p+".tmp", b, 0644)
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(p+".tmp", p)
+}
+
+func (w *Worker) loadPlaylist() (*Playlist, error) {
+	b, err := os.ReadFile(path.Join(w.dir, playlistFile))
+	if err != nil {
+		return nil, err
+	}
+
+	var p Playlist
+	err = json.Unmarshal(b, &p)
+	if err != nil {
+		return nil, err
+	}
+
+	if p.ID != w.id {
+		err = fmt.Errorf("%s != %s", p.ID, w.id)
+		return nil, err
+	}
+
+	return &p, nil
+}
+
 func (w *Worker) ID() string    { return w.id }
-func (w *Worker) Alias() string { return w.alias }
+func (w *Worker) Alias() string { return *w.alias.Load() }
+
+func (w *Worker) Options() PlaylistOptions { return *w.opts.Load() }
+
+func (w *Worker) PlaylistInfo() PlaylistInfo { return w.playlist.Load().PlaylistInfo }
+
+// SetOptions applies the options to the running worker,
+// the interval and max clips take effect on the next refresh.
+func (w *Worker) SetOptions(opts PlaylistOptions) {
+	old := w.opts.Swap(&opts)
+	if old.Order != opts.Order {
+		w.queue.SetStrategy(w.newStrategy(opts.Order))
+	}
+	w.queue.SetNoRepeat(opts.NoRepeatSongs, opts.NoRepeatWithin)
+}
+
+// newStrategy seeds the strategy with the persisted play counts if it takes them.
+func (w *Worker) newStrategy(order string) Strategy {
+	strategy := NewStrategy(order)
+	if seeder, ok := strategy.(playsSeeder); ok {
+		seeder.seedPlays(w.history.counts())
+	}
+	return strategy
+}
+
+// convertedList returns the playable clips sorted by the relative index.
+func (w *Worker) convertedList() PlaylistClips {
+	var clips PlaylistClips
+	w.convertedClips.Range(func(_, value any) bool {
+		clips = append(clips, value.(*PlaylistClip))
+		return true
+	})
+	sort.Stable(clips)
+	return clips
+}
+
+// Upcoming peeks at the next n clips.
+func (w *Worker) Upcoming(n int) []map[string]any {
+	infos := []map[string]any{}
+	for _, clip := range w.queue.Peek(w.convertedList(), n) {
+		infos = append(infos, clip.Info())
+	}
+	return infos
+}
+
+// Skip stops the current clip at the next page.
+func (w *Worker) Skip() error {
+	if atomic.LoadInt32(&w.playing) == 0 {
+		return ErrNotPlaying
+	}
+	atomic.StoreInt32(&w.skip, 1)
+	return nil
+}
+
+// Enqueue requests a converted clip of the playlist.
+func (w *Worker) Enqueue(clipID string) error {
+	if _, ok := w.convertedClips.Load(clipID); !ok {
+		return ErrClipNotFound
+	}
+	w.queue.Enqueue(clipID)
+	return nil
+}
+
+// Dequeue removes the clip from the upcoming ones.
+func (w *Worker) Dequeue(clipID string) error {
+	if !w.queue.Remove(clipID) {
+		return ErrClipNotFound
+	}
+	return nil
+}
+
 func (w *Worker) Info() map[string]any {
 
 	m := map[string]any{
-		"info":     w.playlist.PlaylistInfo,
+		"info":     w.playlist.Load().PlaylistInfo,
+		"options":  w.Options(),
 		"listener": atomic.LoadInt32(&w.streamCount),
 	}
 
@@ -77,17 +281,7 @@ func (w *Worker) Info() map[string]any {
 		listeningV := w.listeningCLipID.Load()
 		if listeningV != nil {
 			if clip, ok := listeningV.(*PlaylistClip); ok {
-				var listening = map[string]any{
-					"title":        clip.Clip.Title,
-					"upvote_count": clip.Clip.UpvoteCount,
-					"url":          fmt.Sprintf("https://suno.com/song/%s", clip.Clip.ID),
-				}
-
-				if clip.Clip.Title != "" {
-					listening["title"] = clip.Clip.Title
-				}
-
-				m["listening"] = listening
+				m["listening"] = clip.Info()
 			}
 		}
 	}
@@ -101,37 +295,48 @@ func (w *Worker) Start(ctx context.Context) {
 	go func() {
 		defer w.wg.Done()
 
-		ticker := time.NewTicker(w.interval)
+		interval := w.Options().Interval
+		ticker := time.NewTicker(interval)
 		defer ticker.Stop()
 
 		fetch := func() error {
 			w.logger.InfoContext(ctx, "fetching playlist")
-			playlist, err := GetPlaylist(ctx, w.id, 1)
+			playlist, err := w.client.GetPlaylistAll(ctx, w.id, w.Options().MaxClips)
 			if err != nil {
 				w.logger.ErrorContext(ctx, "fetch playlist", "err", err)
 				return err
 			}
-			w.logger.InfoContext(ctx, "fetched playlist")
+			w.logger.InfoContext(ctx, "fetched playlist", "clips", len(playlist.PlaylistClips), "total", playlist.NumTotalResults)
 
-			w.playlist = playlist
+			w.playlist.Store(playlist)
+			w.publish(EventPlaylistRefresh, map[string]any{
+				"info":  playlist.PlaylistInfo,
+				"clips": len(playlist.PlaylistClips),
+			})
+
+			err = w.savePlaylist(playlist)
+			if err != nil {
+				w.logger.WarnContext(ctx, "save playlist", "err", err)
+			}
 
 			// remove outdated
 			w.convertedClips.Range(func(key, _ any) bool {
 
-				for i := range w.playlist.PlaylistClips {
-					if key.(string) == w.playlist.PlaylistClips[i].Clip.ID {
+				for i := range playlist.PlaylistClips {
+					if key.(string) == playlist.PlaylistClips[i].Clip.ID {
 						return true
 					}
 				}
 
 				w.convertedClips.Delete(key.(string))
+				w.loudness.Delete(key.(string))
 
 				pmp3 := path.Join(w.dir, fmt.Sprintf("%s.mp3", key.(string)))
 				pogg := path.Join(w.dir, fmt.Sprintf("%s.ogg", key.(string)))
 
-				os.Remove(pmp3)
+				RemoveMP3(pmp3)
 				os.Remove(pogg)
-				os.Remove(pmp3 + ".tmp")
+				os.Remove(mp3toogg.LoudnessPath(pogg))
 
 				return true
 			})
@@ -145,13 +350,24 @@ func (w *Worker) Start(ctx context.Context) {
 
 			stat, err := os.Stat(pogg)
 			converted = err == nil && stat != nil && !stat.IsDir() && verifySunoOgg(pogg) == nil
+
+			// the clips converted before the loudness analysis are converted again if their mp3 is still there
+			if converted && w.Options().TargetLUFS != 0 {
+				if _, err := os.Stat(mp3toogg.LoudnessPath(pogg)); err != nil {
+					stat, err := os.Stat(pmp3)
+					if err == nil && !stat.IsDir() && CheckMP3(pmp3) == nil {
+						return true, false
+					}
+				}
+			}
+
 			if converted {
 				downloaded = true
 				return
 			}
 
 			stat, err = os.Stat(pmp3)
-			downloaded = err == nil && stat != nil && !stat.IsDir()
+			downloaded = err == nil && stat != nil && !stat.IsDir() && CheckMP3(pmp3) == nil
 			if !downloaded {
 				return
 			}
@@ -164,7 +380,10 @@ func (w *Worker) Start(ctx context.Context) {
 			var clipsDownloaded, clipsNotDownloaded []*PlaylistClip
 
 			// downloaded clips
-			for _, clip := range w.playlist.PlaylistClips {
+			for _, clip := range w.playlist.Load().PlaylistClips {
+				if !clip.Playable() {
+					continue
+				}
 				downloaded, _ := isFilePrepared(clip)
 				if downloaded {
 					clipsDownloaded = append(clipsDownloaded, clip)
@@ -194,7 +413,7 @@ func (w *Worker) Start(ctx context.Context) {
 				if !downloaded {
 
 					w.logger.InfoContext(ctx, "downloading mp3", "p", pmp3)
-					err := DownloadMP3(ctx, clip.Clip.AudioURL, pmp3)
+					err := w.client.DownloadMP3(ctx, clip.Clip.AudioURL, pmp3)
 					if err != nil {
 						w.logger.ErrorContext(ctx, "download mp3", "p", pmp3, "err", err)
 						continue
@@ -235,6 +454,11 @@ func (w *Worker) Start(ctx context.Context) {
 			case <-ctx.Done():
 				return
 			case <-ticker.C:
+				if newInterval := w.Options().Interval; newInterval != interval {
+					interval = newInterval
+					ticker.Reset(interval)
+				}
+
 				err := fetch()
 				if err != nil {
 					continue
@@ -245,8 +469,6 @@ func (w *Worker) Start(ctx context.Context) {
 
 	}()
 
-	rnd := rand.New(rand.NewSource(time.Now().UnixNano()))
-
 	w.wg.Add(1)
 	go func() {
 		defer w.wg.Done()
@@ -268,43 +490,57 @@ func (w *Worker) Start(ctx context.Context) {
 				continue
 			}
 
-			var clipIDs []string
-			w.convertedClips.Range(func(key, _ any) bool {
-				clipIDs = append(clipIDs, key.(string))
-				return true
-			})
-
-			if len(clipIDs) == 0 {
-				continue
-			}
-
-			clipID := clipIDs[rnd.Intn(len(clipIDs))]
-
-			clipV, ok := w.convertedClips.Load(clipID)
-			if !ok {
+			clip := w.queue.Next(w.convertedList())
+			if clip == nil {
+				w.flushFade(ctx)
 				continue
 			}
 
-			clip := clipV.(*PlaylistClip)
-
 			pogg := path.Join(w.dir, fmt.Sprintf("%s.ogg", clip.Clip.ID))
 
 			f, err := os.Open(pogg)
 			if err != nil {
 				w.logger.ErrorContext(ctx, "open ogg", "p", pogg, "err", err)
+				w.flushFade(ctx)
 				continue
 			}
 
 			w.logger.InfoContext(ctx, "streaming ogg", "p", pogg)
+			w.loadLoudness(clip, pogg)
 			w.listeningCLipID.Store(clip)
-			err = w.streamOgg(ctx, f)
+			w.votes.reset(clip.Clip.ID)
+			w.publish(EventTrackChange, clip.Info())
+			atomic.StoreInt32(&w.skip, 0)
+			atomic.StoreInt32(&w.playing, 1)
+			if err = w.history.start(clip, atomic.LoadInt32(&w.streamCount)); err != nil {
+				w.logger.WarnContext(ctx, "save history", "err", err)
+			}
+			// the MP3 listeners miss the clip if its MP3 is gone
+			var follower *mp3Follower
+			fmp3, errMP3 := os.Open(path.Join(w.dir, fmt.Sprintf("%s.mp3", clip.Clip.ID)))
+			if errMP3 == nil {
+				follower = newMP3Follower(fmp3)
+			}
+			skipped, err := w.streamOgg(ctx, clip, f, follower)
+			atomic.StoreInt32(&w.playing, 0)
 			f.Close()
+			if fmp3 != nil {
+				fmp3.Close()
+			}
+			if errFinish := w.history.finish(skipped); errFinish != nil {
+				w.logger.WarnContext(ctx, "save history", "err", errFinish)
+			}
 			if err != nil {
 				w.logger.ErrorContext(ctx, "stream ogg", "p", pogg, "err", err)
+				w.flushFade(ctx)
 				continue
 			}
 
-			// TODO silence
+			// the tail of the clip is held back for the crossfade
+			if gap := w.Options().Gap; gap > 0 && w.fade.prev == nil {
+				// only fails when canceled
+				_ = w.streamSilence(ctx, clip, gap)
+			}
 
 		}
 
@@ -313,61 +549,180 @@ func (w *Worker) Start(ctx context.Context) {
 }
 
 func (w *Worker) Close() error {
+	w.closeMu.Lock()
 	atomic.StoreInt32(&w.canceled, 1)
 	w.broadcaster.Close()
+	w.events.Close()
+	w.mp3Broadcaster.Close()
+	w.closeMu.Unlock()
+
 	w.wg.Wait()
 	return nil
 }
 
+// listen adds a listener to the relay of the worker, the relay panics once it's closed.
+func listen[T any](w *Worker, relay *broadcast.Relay[T], capacity int) (*broadcast.Listener[T], error) {
+	w.closeMu.Lock()
+	defer w.closeMu.Unlock()
+
+	if atomic.LoadInt32(&w.canceled) != 0 {
+		return nil, ErrWorkerClosed
+	}
+	return relay.Listener(capacity), nil
+}
+
 type oggPage struct {
+	clip *PlaylistClip
+	// the station granule after the packets
 	granule int64
 	packets [][]byte
+	// the PreSkip of the clip on its first page, a logical stream starting there uses it
+	preSkip uint16
+	// the padding samples at the end of the clip on its last page,
+	// only an EOS page can drop them
+	trim int64
+	// the frames of the original MP3 up to the page, broadcast when it's published
+	mp3 []*mp3Chunk
 }
 
-func (w *Worker) Stream(id string, ctx context.Context, writer io.Writer) error {
+// Stream writes the radio to the writer until the ctx is done,
+// the session identifies the listener for the skip votes.
+// A ClipWriter is told about the clip changes.
+func (w *Worker) Stream(session string, ctx context.Context, writer io.Writer) error {
 
 	oggwriter := ogg.NewEncoder(DefaultOggSerial, writer)
 
-	listener := w.broadcaster.Listener(1)
-	defer listener.Close()
-	w.logger.Info("stream created", "stream id", id)
-	defer w.logger.Info("stream exited", "stream id", id)
+	opts := w.Options()
+
+	leave, err := w.join(session, opts)
+	if err != nil {
+		return err
+	}
+	defer leave()
 
-	atomic.AddInt32(&w.streamCount, 1)
-	defer atomic.AddInt32(&w.streamCount, -1)
+	burst, listener, err := w.subscribe()
+	if err != nil {
+		return err
+	}
+	defer listener.Close()
 
 	idh := &ogg.IDHeader{
 		Version:            1,
 		OutputChannelCount: DefaultChannels,
 		PreSkip:            0,
 		InputSampleRate:    DefaultSampleRate,
+		OutputGainQ7_8:     opts.GainQ7_8(),
 	}
 
-	packets, err := idh.Encode()
-	if err != nil {
-		return err
+	serial := uint32(DefaultOggSerial)
+
+	writeHeaders := func(tags map[string]string) error {
+		oggwriter.SetSerial(serial)
+		oggwriter.SetPageSeq(0)
+
+		packets, err := idh.Encode()
+		if err != nil {
+			return err
+		}
+
+		err = oggwriter.EncodeBOS(0, packets)
+		if err != nil {
+			return err
+		}
+
+		cmh := &ogg.CommentHeader{
+			VendorString:    ProjectName,
+			UserCommentList: tags,
+		}
+
+		packets, err = cmh.Encode()
+		if err != nil {
+			return err
+		}
+
+		return oggwriter.Encode(0, packets)
 	}
 
-	err = oggwriter.EncodeBOS(0, packets)
-	if err != nil {
-		return err
+	// a logical stream of the chained stream has the pre-skip and the loudness of its clip
+	writeClipHeaders := func(page *oggPage) error {
+		idh.PreSkip = page.preSkip
+		w.clipHeader(idh, page.clip, opts)
+		return writeHeaders(w.clipTags(page.clip, opts))
 	}
 
-	cmh := &ogg.CommentHeader{
-		VendorString: ProjectName,
-		UserCommentList: map[string]string{
+	// the chained stream waits for the first page to know its clip,
+	// the single logical stream has no pre-skip, the clips play with their encoder delay and padding
+	if !opts.Chained {
+		err := writeHeaders(map[string]string{
 			"CONTACT": ProjectURL,
-		},
+		})
+		if err != nil {
+			return err
+		}
 	}
 
-	packets, err = cmh.Encode()
-	if err != nil {
-		return err
+	// the chained stream holds a page back to end the logical stream on the last page of the clip
+	var (
+		pending *oggPage
+		// the granules start from the first page of the listener
+		base  int64
+		based bool
+	)
+
+	clipWriter, _ := writer.(ClipWriter)
+	var lastClip *PlaylistClip
+
+	write := func(page *oggPage) error {
+		w.logger.Debug("Subscribe got msg", "session", session, "granule", page.granule)
+
+		if !based {
+			based = true
+			base = page.granule - opusSamples(page.packets)
+		}
+
+		if clipWriter != nil && page.clip != lastClip {
+			clipWriter.SetClip(page.clip)
+			lastClip = page.clip
+		}
+
+		if !opts.Chained {
+			return oggwriter.Encode(page.granule-base, page.packets)
+		}
+
+		if pending == nil {
+			err := writeClipHeaders(page)
+			if err != nil {
+				return err
+			}
+			pending = page
+			return nil
+		}
+
+		var err error
+		if page.clip == pending.clip {
+			err = oggwriter.Encode(pending.granule-base, pending.packets)
+		} else {
+			err = oggwriter.EncodeEOS(pending.granule-base-pending.trim, pending.packets)
+			if err == nil {
+				// the granule restarts in every logical stream
+				base = pending.granule
+				serial++
+				err = writeClipHeaders(page)
+			}
+		}
+		if err != nil {
+			return err
+		}
+		pending = page
+		return nil
 	}
 
-	err = oggwriter.Encode(0, packets)
-	if err != nil {
-		return err
+	// the new listener starts with the last seconds before the live pages
+	for _, page := range burst {
+		err := write(page)
+		if err != nil {
+			return err
+		}
 	}
 
 	for {
@@ -375,55 +730,143 @@ func (w *Worker) Stream(id string, ctx context.Context, writer io.Writer) error
 		case <-ctx.Done():
 			return context.Canceled
 		case page := <-listener.Ch():
-			{
-				if page == nil {
-					// ???
-					return context.Canceled
-				}
-
-				if atomic.LoadInt32(&w.canceled) != 0 {
-					return context.Canceled
-				}
+			if page == nil {
+				// ???
+				return context.Canceled
+			}
 
-				w.logger.Debug("Subscribe got msg", "stream id", id, "granule", page.granule)
-				defer w.logger.Debug("Subscribe got msg exited", "stream id", id, "granule", page.granule)
+			if atomic.LoadInt32(&w.canceled) != 0 {
+				return context.Canceled
+			}
 
-				err := oggwriter.Encode(page.granule, page.packets)
-				if err != nil {
-					return err
-				}
+			err := write(page)
+			if err != nil {
+				return err
 			}
 		}
 	}
 
 }
 
-func (w *Worker) streamOgg(ctx context.Context, f io.Reader) error {
+// join counts the listener of the session, the returned func must be called when it leaves.
+func (w *Worker) join(session string, opts PlaylistOptions) (func(), error) {
+	// the first listener starts the station again, the burst is reset under the lock publish takes,
+	// so no page published while the station was stopping is left
+	w.burst.mu.Lock()
+	count := atomic.AddInt32(&w.streamCount, 1)
+	if count == 1 {
+		w.burst.reset()
+	}
+	w.burst.mu.Unlock()
+	if opts.MaxListeners > 0 && int(count) > opts.MaxListeners {
+		atomic.AddInt32(&w.streamCount, -1)
+		return nil, ErrTooManyListeners
+	}
+	w.publishListenerCount()
+	w.votes.join(session)
+	w.logger.Info("stream created", "session", session)
+
+	return func() {
+		w.logger.Info("stream exited", "session", session)
+		w.votes.leave(session)
+		// the station stops until the next listener
+		atomic.AddInt32(&w.streamCount, -1)
+		w.publishListenerCount()
+	}, nil
+}
+
+// clipTags are the OpusTags of the clip in the chained stream.
+func (w *Worker) clipTags(clip *PlaylistClip, opts PlaylistOptions) map[string]string {
+	tags := map[string]string{
+		"CONTACT": ProjectURL,
+	}
+
+	for k, v := range map[string]string{
+		"TITLE":  clip.Clip.Title,
+		"ARTIST": clip.Clip.DisplayName,
+		"ALBUM":  w.playlist.Load().Name,
+		"URL":    clip.URL(),
+		"GENRE":  clip.Clip.Metadata.Tags,
+	} {
+		if v != "" {
+			tags[k] = v
+		}
+	}
+
+	// the output gain has taken the clip to the target already
+	if _, ok := w.clipGain(clip, opts); ok {
+		tags["R128_TRACK_GAIN"] = strconv.Itoa(int(gainQ7_8(r128Reference - opts.TargetLUFS - opts.Gain)))
+	}
+
+	return tags
+}
+
+func copyPackets(packets [][]byte) [][]byte {
+	copied := make([][]byte, len(packets))
+	for i := range packets {
+		copied[i] = append([]byte(nil), packets[i]...)
+	}
+	return copied
+}
+
+// streamOgg broadcasts the pages of the clip, reports whether it was skipped.
+func (w *Worker) streamOgg(ctx context.Context, clip *PlaylistClip, f io.Reader, follower *mp3Follower) (bool, error) {
 	d := ogg.NewDecoder(f)
 
-	var lastGranule int64
+	var (
+		idh ogg.IDHeader
+		// the granule of the previous page, -1 before the first audio page
+		lastGranule int64 = -1
+		first             = true
+	)
+
+	var trimmer *silenceTrimmer
+	if w.Options().TrimSilence {
+		trimmer = &silenceTrimmer{}
+	}
+
+	var fader *crossfader
+	if d := w.Options().Crossfade; d > 0 && CrossfadeSupported() {
+		fader = w.crossfader(d)
+	} else {
+		w.flushFade(ctx)
+	}
 
 	for atomic.LoadInt32(&w.streamCount) > 0 {
 		if atomic.LoadInt32(&w.canceled) != 0 {
-			return context.Canceled
+			return false, context.Canceled
 		}
 
 		select {
 		case <-ctx.Done():
-			return context.Canceled
+			return false, context.Canceled
 		default:
 		}
 
+		// the next clip continues from w.granule, so the listeners don't notice
+		if atomic.CompareAndSwapInt32(&w.skip, 1, 0) {
+			w.logger.InfoContext(ctx, "skipped")
+			if fader != nil {
+				w.endFade(ctx, fader)
+			}
+			return true, nil
+		}
+
 		p, err := d.Decode()
 		if err != nil {
 			if errors.Is(err, io.EOF) {
 				break
 			}
 			w.logger.ErrorContext(ctx, "open decode", "err", err)
-			return err
+			return false, err
 		}
 
 		if p.Type&ogg.BOS == ogg.BOS {
+			err = idh.Decode(p.Packets)
+			if err != nil {
+				w.logger.ErrorContext(ctx, "ogg IDHeader", "err", err)
+				return false, err
+			}
 			continue
 		}
 
@@ -442,40 +885,88 @@ func (w *Worker) streamOgg(ctx context.Context, f io.Reader) error {
 			continue
 		}
 
-		if p.Type&ogg.EOS == ogg.EOS {
-			w.logger.DebugContext(ctx, "ogg EOS")
-			// TODO padding EOS page's PCM to 'full' opus page size
+		// the station granule counts every sample of the packets, the granule of the page doesn't
+		// on the EOS page, where the encoder padding is trimmed
+		var pcmLen int64
+		for _, packet := range p.Packets {
+			pcmLen += int64(ogg.OpusPacketSamples(packet))
 		}
-
-		pcmLen := p.Granule - lastGranule
 		if pcmLen == 0 {
 			continue
 		}
 
-		if w.granule == 0 {
-			w.beginTime = time.Now()
+		if lastGranule < 0 {
+			lastGranule = max(0, p.Granule-pcmLen)
+		}
+		var trim int64
+		if p.Type&ogg.EOS == ogg.EOS {
+			trim = max(0, lastGranule+pcmLen-p.Granule)
+			w.logger.DebugContext(ctx, "ogg EOS", "trim", trim)
 		}
-
-		w.granule += pcmLen
-		w.logger.DebugContext(ctx, "publishing ogg page", "len", pcmLen, "granule", w.granule)
-		w.broadcaster.Broadcast(&oggPage{
-			granule: w.granule,
-			packets: p.Packets,
-		})
-		w.logger.DebugContext(ctx, "published ogg page", "len", pcmLen, "granule", w.granule)
 		lastGranule = p.Granule
 
-		// make clients' memory happy
-		time.Sleep(time.Millisecond * 900 * time.Duration(pcmLen) / 48000)
-		ms := time.Duration(w.granule) * 1000 * time.Millisecond / 48000
-		expect := w.beginTime.Add(ms)
-		sub := time.Until(expect)
-		if sub > time.Millisecond*2000 {
-			wait := sub - time.Millisecond*2000
-			time.Sleep(wait)
+		// the decoder reuses its buffer on the next page, the listeners may hold the page longer
+		packets := copyPackets(p.Packets)
+		preSkip := int64(idh.PreSkip)
+		if trimmer != nil {
+			packets, pcmLen = trimmer.trim(packets, pcmLen)
+			if len(packets) == 0 {
+				continue
+			}
+			// the leading silence took the pre-skip with it
+			preSkip = max(0, preSkip-trimmer.skipped)
+			// the padding is in the trailing silence held back
+			if len(trimmer.held) > 0 {
+				trim = 0
+			}
+		}
+
+		page := &oggPage{clip: clip, packets: packets, trim: trim}
+		if first {
+			page.preSkip = uint16(preSkip)
+			first = false
+		}
+
+		// the frames go out with the page, after the gap, the trimming and the fade held it back
+		if follower != nil {
+			frames := follower.until(time.Duration(p.Granule-int64(idh.PreSkip)) * time.Second / DefaultSampleRate)
+			if len(frames) > 0 {
+				page.mp3 = []*mp3Chunk{{clip: clip, frames: frames}}
+			}
+		}
+
+		if fader != nil {
+			w.fadePage(ctx, fader, page, pcmLen)
+			continue
 		}
+		w.publishOpus(ctx, page, pcmLen)
+	}
 
+	if fader != nil {
+		w.endFade(ctx, fader)
 	}
+	return false, nil
+}
 
-	return nil
+// publishOpus broadcasts the page at the next station granule and paces the clips.
+func (w *Worker) publishOpus(ctx context.Context, page *oggPage, pcmLen int64) {
+	if w.granule == 0 {
+		w.beginTime = time.Now()
+	}
+
+	w.granule += pcmLen
+	page.granule = w.granule
+	w.logger.DebugContext(ctx, "publishing ogg page", "len", pcmLen, "granule", w.granule)
+	w.burst.publish(w.broadcaster, w.mp3Broadcaster, page, pcmLen)
+	w.logger.DebugContext(ctx, "published ogg page", "len", pcmLen, "granule", w.granule)
+
+	// make clients' memory happy
+	time.Sleep(time.Millisecond * 900 * time.Duration(pcmLen) / 48000)
+	ms := time.Duration(w.granule) * 1000 * time.Millisecond / 48000
+	expect := w.beginTime.Add(ms)
+	sub := time.Until(expect)
+	if sub > time.Millisecond*2000 {
+		wait := sub - time.Millisecond*2000
+		time.Sleep(wait)
+	}
 }
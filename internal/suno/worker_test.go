@@ -0,0 +1,403 @@
+package suno
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/hellodword/suno-radio/internal/mp3toogg"
+	"github.com/hellodword/suno-radio/internal/ogg"
+	"github.com/teivah/broadcast"
+)
+
+func newTestWorker(opts PlaylistOptions) *Worker {
+	w := &Worker{
+		logger:         slog.New(slog.NewTextHandler(io.Discard, nil)),
+		broadcaster:    broadcast.NewRelay[*oggPage](),
+		mp3Broadcaster: broadcast.NewRelay[*mp3Chunk](),
+		hls:            newHLSSegmenter(),
+		events:         broadcast.NewRelay[*Event](),
+		votes:          newSkipVotes(),
+	}
+	w.opts.Store(&opts)
+	w.playlist.Store(&Playlist{PlaylistInfo: PlaylistInfo{ID: "test", Name: "Test Album"}})
+	return w
+}
+
+// firstWriter closes written on the first write, the stream writes only after it subscribed.
+type firstWriter struct {
+	io.Writer
+	once    sync.Once
+	written chan struct{}
+}
+
+func newFirstWriter(writer io.Writer) *firstWriter {
+	return &firstWriter{Writer: writer, written: make(chan struct{})}
+}
+
+func (w *firstWriter) Write(p []byte) (int, error) {
+	defer w.once.Do(func() { close(w.written) })
+	return w.Writer.Write(p)
+}
+
+// streamPages streams the pages to a listener and returns the decoded ogg pages,
+// the pages after the first are live.
+func streamPages(t *testing.T, w *Worker, pages []*oggPage) []ogg.Page {
+	t.Helper()
+
+	var buf bytes.Buffer
+	writer := newFirstWriter(&buf)
+	done := make(chan error)
+	go func() {
+		done <- w.Stream("test", context.Background(), writer)
+	}()
+
+	// the burst is reset when the station starts, the first page is published after
+	for atomic.LoadInt32(&w.streamCount) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	// the listener gets the first page once, from the burst or live, whenever it subscribes
+	w.burst.publish(w.broadcaster, w.mp3Broadcaster, pages[0], opusSamples(pages[0].packets))
+	<-writer.written
+	for _, page := range pages[1:] {
+		w.broadcaster.Notify(page)
+	}
+	// the stream ends once it has read the pages
+	w.broadcaster.Close()
+
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Fatal(err)
+	}
+
+	var decoded []ogg.Page
+	d := ogg.NewDecoder(&buf)
+	for {
+		p, err := d.Decode()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		p.Packets = copyPackets(p.Packets)
+		decoded = append(decoded, p)
+	}
+	return decoded
+}
+
+func TestStreamChained(t *testing.T) {
+	clips := testClips(3)
+	clips[0].Clip.Title = "first"
+	clips[1].Clip.Title = "second"
+
+	var pages []*oggPage
+	for i, clip := range []*PlaylistClip{clips[0], clips[0], clips[1], clips[1], clips[2]} {
+		pages = append(pages, &oggPage{clip: clip, granule: int64(i+1) * 960, packets: [][]byte{{252, byte(i)}}})
+	}
+
+	decoded := streamPages(t, newTestWorker(PlaylistOptions{Chained: true}), pages)
+	if len(decoded) < 10 {
+		t.Fatalf("%d pages", len(decoded))
+	}
+
+	for i, want := range []struct {
+		typ     byte
+		serial  uint32
+		granule int64
+		title   string
+	}{
+		{ogg.BOS, 1, 0, ""},
+		{0, 1, 0, "first"},
+		{0, 1, 960, ""},
+		{ogg.EOS, 1, 1920, ""},
+		{ogg.BOS, 2, 0, ""},
+		{0, 2, 0, "second"},
+		{0, 2, 960, ""},
+		{ogg.EOS, 2, 1920, ""},
+		{ogg.BOS, 3, 0, ""},
+		{0, 3, 0, clips[2].Clip.Title},
+	} {
+		p := decoded[i]
+		if p.Type != want.typ || p.Serial != want.serial || p.Granule != want.granule {
+			t.Fatalf("page %d: type %d serial %d granule %d, want %+v", i, p.Type, p.Serial, p.Granule, want)
+		}
+
+		var cmh ogg.CommentHeader
+		if cmh.Decode(p.Packets) == nil {
+			if cmh.UserCommentList["TITLE"] != want.title || cmh.UserCommentList["ALBUM"] != "Test Album" {
+				t.Fatalf("page %d: tags %v", i, cmh.UserCommentList)
+			}
+		} else if want.title != "" {
+			t.Fatalf("page %d: no tags", i)
+		}
+	}
+}
+
+func TestStreamUnchained(t *testing.T) {
+	clips := testClips(2)
+
+	var pages []*oggPage
+	for i, clip := range []*PlaylistClip{clips[0], clips[1]} {
+		pages = append(pages, &oggPage{clip: clip, granule: int64(i+1) * 960, packets: [][]byte{{252, byte(i)}}})
+	}
+
+	decoded := streamPages(t, newTestWorker(PlaylistOptions{}), pages)
+	if len(decoded) < 4 {
+		t.Fatalf("%d pages", len(decoded))
+	}
+
+	for i, p := range decoded {
+		if p.Serial != DefaultOggSerial || (i > 0 && p.Type != 0) {
+			t.Fatalf("page %d: type %d serial %d", i, p.Type, p.Serial)
+		}
+	}
+	if decoded[2].Granule != 960 || decoded[3].Granule != 1920 {
+		t.Fatalf("granules %d %d", decoded[2].Granule, decoded[3].Granule)
+	}
+}
+
+var update = flag.Bool("update", false, "update the golden files")
+
+// testOggClip encodes an Opus file of 20ms packets, the packets of every page are counted
+// by pages, the last page trims the padding samples.
+func testOggClip(t *testing.T, preSkip uint16, pages []int, padding int64) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	e := ogg.NewEncoder(DefaultOggSerial, &buf)
+
+	idh := &ogg.IDHeader{Version: 1, OutputChannelCount: 2, PreSkip: preSkip, InputSampleRate: DefaultSampleRate}
+	packets, err := idh.Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = e.EncodeBOS(0, packets); err != nil {
+		t.Fatal(err)
+	}
+
+	cmh := &ogg.CommentHeader{VendorString: "test"}
+	packets, err = cmh.Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = e.Encode(0, packets); err != nil {
+		t.Fatal(err)
+	}
+
+	var granule int64
+	for i, n := range pages {
+		packets = nil
+		for j := range n {
+			packets = append(packets, []byte{252, byte(i), byte(j), 0xff})
+			granule += 960
+		}
+
+		if i < len(pages)-1 {
+			err = e.Encode(granule, packets)
+		} else {
+			err = e.EncodeEOS(granule-padding, packets)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return buf.Bytes()
+}
+
+func dumpPages(b *strings.Builder, pages []ogg.Page) {
+	for _, p := range pages {
+		fmt.Fprintf(b, "type=%d serial=%d granule=%d packets=%d", p.Type, p.Serial, p.Granule, len(p.Packets))
+		var idh ogg.IDHeader
+		if idh.Decode(p.Packets) == nil {
+			fmt.Fprintf(b, " pre_skip=%d", idh.PreSkip)
+		}
+		b.WriteString("\n")
+	}
+}
+
+// TestSplice splices the clips with their own pre-skip and end trimming,
+// and compares the station pages and the streams with testdata/splice.golden.
+func TestSplice(t *testing.T) {
+	clips := testClips(3)
+	files := [][]byte{
+		testOggClip(t, 312, []int{2, 2, 1}, 500),
+		// the pre-skip is longer than a packet, and no padding
+		testOggClip(t, 3840, []int{3, 1}, 0),
+	}
+
+	w := newTestWorker(PlaylistOptions{})
+	atomic.StoreInt32(&w.streamCount, 1)
+
+	listener := w.broadcaster.Listener(64)
+	defer listener.Close()
+
+	for i, f := range files {
+		_, err := w.streamOgg(context.Background(), clips[i], bytes.NewReader(f), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	atomic.StoreInt32(&w.streamCount, 0)
+
+	var b strings.Builder
+	var pages []*oggPage
+
+	b.WriteString("station\n")
+	for len(listener.Ch()) > 0 {
+		page := <-listener.Ch()
+		pages = append(pages, page)
+		fmt.Fprintf(&b, "clip=%s granule=%d packets=%d pre_skip=%d trim=%d\n",
+			page.clip.Clip.ID, page.granule, len(page.packets), page.preSkip, page.trim)
+	}
+
+	// the third clip ends the logical stream of the second one
+	pages = append(pages, &oggPage{clip: clips[2], granule: pages[len(pages)-1].granule + 960, packets: [][]byte{opusSilence}})
+
+	b.WriteString("chained\n")
+	var chained []ogg.Page
+	for _, p := range streamPages(t, newTestWorker(PlaylistOptions{Chained: true}), pages) {
+		if p.Serial < DefaultOggSerial+2 {
+			chained = append(chained, p)
+		}
+	}
+	dumpPages(&b, chained)
+
+	b.WriteString("unchained\n")
+	// the headers and the pages of the two clips
+	dumpPages(&b, streamPages(t, newTestWorker(PlaylistOptions{}), pages)[:2+len(pages)-1])
+
+	golden := filepath.Join("testdata", "splice.golden")
+	if *update {
+		err := os.WriteFile(golden, []byte(b.String()), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	want, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.String() != string(want) {
+		t.Fatalf("got:\n%s\nwant:\n%s", b.String(), want)
+	}
+}
+
+func TestStreamChainedLoudness(t *testing.T) {
+	clips := testClips(3)
+
+	w := newTestWorker(PlaylistOptions{Chained: true, Gain: 1, TargetLUFS: -14})
+	w.loudness.Store(clips[0].Clip.ID, &mp3toogg.Loudness{Integrated: -20})
+
+	var pages []*oggPage
+	for i, clip := range clips {
+		pages = append(pages, &oggPage{clip: clip, granule: int64(i+1) * 960, packets: [][]byte{{252, byte(i)}}})
+	}
+
+	var headers []ogg.Page
+	for _, p := range streamPages(t, w, pages) {
+		if p.Granule == 0 && p.Serial < DefaultOggSerial+2 {
+			headers = append(headers, p)
+		}
+	}
+	if len(headers) != 4 {
+		t.Fatalf("%d header pages", len(headers))
+	}
+
+	for i, want := range []struct {
+		gain  int16
+		track string
+	}{
+		// 6dB to the target and the station gain
+		{7 * 256, "-2560"},
+		// no analysis
+		{256, ""},
+	} {
+		var idh ogg.IDHeader
+		if err := idh.Decode(headers[i*2].Packets); err != nil {
+			t.Fatal(err)
+		}
+		var cmh ogg.CommentHeader
+		if err := cmh.Decode(headers[i*2+1].Packets); err != nil {
+			t.Fatal(err)
+		}
+
+		if idh.OutputGainQ7_8 != want.gain || cmh.UserCommentList["R128_TRACK_GAIN"] != want.track {
+			t.Fatalf("clip %d: gain %d, tags %v", i, idh.OutputGainQ7_8, cmh.UserCommentList)
+		}
+	}
+}
+
+func TestPlaylistCache(t *testing.T) {
+	s, _ := newTestPlaylistServer(t, 5, 2, 0)
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	dir := t.TempDir()
+
+	w, err := NewWorker(context.Background(), logger, newTestClient(s.URL), testPlaylistID, "", PlaylistOptions{MaxClips: -1}, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := w.loadPlaylist()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.ID != testPlaylistID || len(p.PlaylistClips) != 5 || p.PlaylistClips[4].Clip.ID != "clip-4" {
+		t.Fatalf("loaded %s with %d clips", p.ID, len(p.PlaylistClips))
+	}
+
+	// suno is unreachable, the cached playlist is played
+	s.Close()
+	w, err = NewWorker(context.Background(), logger, newTestClient(s.URL), testPlaylistID, "", PlaylistOptions{MaxClips: -1}, dir)
+	if err != nil {
+		t.Fatal("unexpected NewWorker error:", err)
+	}
+	if n := len(w.playlist.Load().PlaylistClips); n != 5 {
+		t.Fatalf("got %d clips, expected 5", n)
+	}
+
+	// the cache of another playlist isn't used
+	_, err = NewWorker(context.Background(), logger, newTestClient(s.URL), "another", "", PlaylistOptions{MaxClips: -1}, dir)
+	if err == nil {
+		t.Fatal("expected NewWorker error")
+	}
+}
+
+func TestWorkerClosed(t *testing.T) {
+	w := newTestWorker(PlaylistOptions{})
+	w.Close()
+
+	for name, f := range map[string]func() error{
+		"ogg":  func() error { return w.Stream("test", context.Background(), io.Discard) },
+		"mp3":  func() error { return w.StreamMP3("test", context.Background(), io.Discard) },
+		"webm": func() error { return w.StreamWebM("test", context.Background(), io.Discard) },
+		"hls": func() error {
+			_, err := w.HLSSegment(0)
+			return err
+		},
+		"events": func() error {
+			_, _, err := w.Subscribe()
+			return err
+		},
+	} {
+		if err := f(); !errors.Is(err, ErrWorkerClosed) {
+			t.Fatalf("%s: got %v, expected %v", name, err, ErrWorkerClosed)
+		}
+	}
+
+	if n := atomic.LoadInt32(&w.streamCount); n != 0 {
+		t.Fatalf("%d listeners left", n)
+	}
+}
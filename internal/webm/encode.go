@@ -0,0 +1,245 @@
+// Package webm writes a live WebM stream of a single Opus track,
+// the segment has an unknown size so it can be written as it goes, every ogg page is a cluster.
+package webm
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+
+	"github.com/hellodword/suno-radio/internal/ogg"
+)
+
+const MIMEType = "audio/webm"
+
+// the element IDs keep their length marker bits
+const (
+	idEBML               = 0x1a45dfa3
+	idEBMLVersion        = 0x4286
+	idEBMLReadVersion    = 0x42f7
+	idEBMLMaxIDLength    = 0x42f2
+	idEBMLMaxSizeLength  = 0x42f3
+	idDocType            = 0x4282
+	idDocTypeVersion     = 0x4287
+	idDocTypeReadVersion = 0x4285
+
+	idSegment       = 0x18538067
+	idInfo          = 0x1549a966
+	idTimecodeScale = 0x2ad7b1
+	idMuxingApp     = 0x4d80
+	idWritingApp    = 0x5741
+
+	idTracks            = 0x1654ae6b
+	idTrackEntry        = 0xae
+	idTrackNumber       = 0xd7
+	idTrackUID          = 0x73c5
+	idTrackType         = 0x83
+	idCodecID           = 0x86
+	idCodecPrivate      = 0x63a2
+	idCodecDelay        = 0x56aa
+	idSeekPreRoll       = 0x56bb
+	idAudio             = 0xe1
+	idSamplingFrequency = 0xb5
+	idChannels          = 0x9f
+
+	idCluster     = 0x1f43b675
+	idTimecode    = 0xe7
+	idSimpleBlock = 0xa3
+)
+
+const (
+	trackNumber    = 1
+	trackTypeAudio = 2
+	// 1ms
+	timecodeScale = 1000000
+	// 80ms as recommended for Opus
+	seekPreRoll = 80000000
+	sampleRate  = 48000
+)
+
+// unknownSize is the 8 bytes size with all the bits set
+var unknownSize = []byte{0x01, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}
+
+var ErrHeaderNotWritten = errors.New("header not written")
+
+// An Encoder writes the Opus packets into a live WebM stream.
+type Encoder struct {
+	w       io.Writer
+	app     string
+	started bool
+	// the granule of the first sample, the timecodes start from 0
+	based bool
+	base  int64
+	// the end of the last cluster
+	last int64
+}
+
+func NewEncoder(w io.Writer, app string) *Encoder {
+	return &Encoder{w: w, app: app}
+}
+
+// EncodeHeader writes the EBML header, the live segment, the info and the Opus track,
+// the OpusHead is the CodecPrivate.
+func (e *Encoder) EncodeHeader(idh *ogg.IDHeader) error {
+	packets, err := idh.Encode()
+	if err != nil {
+		return err
+	}
+
+	var b bytes.Buffer
+
+	b.Write(element(idEBML, concat(
+		uintElement(idEBMLVersion, 1),
+		uintElement(idEBMLReadVersion, 1),
+		uintElement(idEBMLMaxIDLength, 4),
+		uintElement(idEBMLMaxSizeLength, 8),
+		element(idDocType, []byte("webm")),
+		uintElement(idDocTypeVersion, 4),
+		uintElement(idDocTypeReadVersion, 2),
+	)))
+
+	b.Write(appendID(nil, idSegment))
+	b.Write(unknownSize)
+
+	b.Write(element(idInfo, concat(
+		uintElement(idTimecodeScale, timecodeScale),
+		element(idMuxingApp, []byte(e.app)),
+		element(idWritingApp, []byte(e.app)),
+	)))
+
+	b.Write(element(idTracks, element(idTrackEntry, concat(
+		uintElement(idTrackNumber, trackNumber),
+		uintElement(idTrackUID, trackNumber),
+		uintElement(idTrackType, trackTypeAudio),
+		element(idCodecID, []byte("A_OPUS")),
+		element(idCodecPrivate, packets[0]),
+		uintElement(idCodecDelay, uint64(idh.PreSkip)*1000000000/sampleRate),
+		uintElement(idSeekPreRoll, seekPreRoll),
+		element(idAudio, concat(
+			floatElement(idSamplingFrequency, sampleRate),
+			uintElement(idChannels, uint64(idh.OutputChannelCount)),
+		)),
+	))))
+
+	_, err = e.w.Write(b.Bytes())
+	if err != nil {
+		return err
+	}
+
+	e.started = true
+	return nil
+}
+
+// Encode writes the packets ending at the granule as a cluster of SimpleBlocks,
+// like ogg.Encoder.Encode with the pages of an Opus stream.
+func (e *Encoder) Encode(granule int64, packets [][]byte) error {
+	if !e.started {
+		return ErrHeaderNotWritten
+	}
+
+	samples := 0
+	for _, packet := range packets {
+		samples += ogg.OpusPacketSamples(packet)
+	}
+
+	start := granule - int64(samples)
+	if !e.based {
+		e.based = true
+		e.base = start
+	}
+	start -= e.base
+	// the granules of a listener never go back, but a cluster can't start before the last one
+	start = max(start, e.last)
+
+	var blocks []byte
+	pos := start
+	for _, packet := range packets {
+		if len(packet) == 0 {
+			continue
+		}
+
+		// the relative timecode is an int16 of ms
+		rel := (pos - start) * 1000 / sampleRate
+		if rel > math.MaxInt16 {
+			break
+		}
+
+		block := []byte{0x80 | trackNumber}
+		block = binary.BigEndian.AppendUint16(block, uint16(rel))
+		// keyframe
+		block = append(block, 0x80)
+		block = append(block, packet...)
+
+		blocks = append(blocks, element(idSimpleBlock, block)...)
+		pos += int64(ogg.OpusPacketSamples(packet))
+	}
+	e.last = pos
+
+	if len(blocks) == 0 {
+		return nil
+	}
+
+	cluster := element(idCluster, concat(
+		uintElement(idTimecode, uint64(start*1000/sampleRate)),
+		blocks,
+	))
+
+	_, err := e.w.Write(cluster)
+	return err
+}
+
+func concat(elements ...[]byte) []byte {
+	return bytes.Join(elements, nil)
+}
+
+func appendID(b []byte, id uint32) []byte {
+	switch {
+	case id > 0xffffff:
+		return append(b, byte(id>>24), byte(id>>16), byte(id>>8), byte(id))
+	case id > 0xffff:
+		return append(b, byte(id>>16), byte(id>>8), byte(id))
+	case id > 0xff:
+		return append(b, byte(id>>8), byte(id))
+	default:
+		return append(b, byte(id))
+	}
+}
+
+// appendSize writes the shortest vint, the all ones values are reserved for the unknown size.
+func appendSize(b []byte, size uint64) []byte {
+	n := 1
+	for n < 8 && size >= 1<<(7*n)-1 {
+		n++
+	}
+
+	v := size | 1<<(7*n)
+	for i := n - 1; i >= 0; i-- {
+		b = append(b, byte(v>>(8*i)))
+	}
+	return b
+}
+
+func element(id uint32, payload []byte) []byte {
+	b := appendID(nil, id)
+	b = appendSize(b, uint64(len(payload)))
+	return append(b, payload...)
+}
+
+func uintElement(id uint32, v uint64) []byte {
+	n := 1
+	for n < 8 && v >= 1<<(8*n) {
+		n++
+	}
+
+	payload := make([]byte, n)
+	for i := range n {
+		payload[n-1-i] = byte(v >> (8 * i))
+	}
+	return element(id, payload)
+}
+
+func floatElement(id uint32, v float64) []byte {
+	return element(id, binary.BigEndian.AppendUint64(nil, math.Float64bits(v)))
+}
@@ -0,0 +1,160 @@
+package webm
+
+import (
+	"bytes"
+	"slices"
+	"testing"
+
+	"github.com/hellodword/suno-radio/internal/ogg"
+)
+
+// readElement returns the id, the payload and the rest, the unknown size takes the rest.
+func readElement(t *testing.T, b []byte) (uint32, []byte, []byte) {
+	t.Helper()
+
+	if len(b) == 0 {
+		t.Fatal("no element")
+	}
+
+	n := 1
+	for b[0]&(0x80>>(n-1)) == 0 {
+		n++
+	}
+	var id uint32
+	for _, v := range b[:n] {
+		id = id<<8 | uint32(v)
+	}
+	b = b[n:]
+
+	n = 1
+	for b[0]&(0x80>>(n-1)) == 0 {
+		n++
+	}
+	size := uint64(b[0] & (0xff >> n))
+	for _, v := range b[1:n] {
+		size = size<<8 | uint64(v)
+	}
+	if bytes.Equal(b[:n], unknownSize) {
+		return id, b[n:], nil
+	}
+	b = b[n:]
+
+	return id, b[:size], b[size:]
+}
+
+func readUint(b []byte) uint64 {
+	var v uint64
+	for _, x := range b {
+		v = v<<8 | uint64(x)
+	}
+	return v
+}
+
+func TestEncoder(t *testing.T) {
+	var buf bytes.Buffer
+	e := NewEncoder(&buf, "test")
+
+	if err := e.Encode(960, nil); err != ErrHeaderNotWritten {
+		t.Fatalf("encode before the header: %v", err)
+	}
+
+	err := e.EncodeHeader(&ogg.IDHeader{Version: 1, OutputChannelCount: 2, InputSampleRate: 48000})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 20ms packets
+	packet := []byte{252, 255, 254}
+	for _, granule := range []int64{48000 + 960*3, 48000 + 960*56} {
+		var packets [][]byte
+		for range 3 {
+			packets = append(packets, packet)
+		}
+		if granule > 48000+960*3 {
+			packets = append(packets, packets...)
+		}
+		err = e.Encode(granule, packets)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	id, payload, rest := readElement(t, buf.Bytes())
+	if id != idEBML {
+		t.Fatalf("id %x", id)
+	}
+	for len(payload) > 0 {
+		var v []byte
+		id, v, payload = readElement(t, payload)
+		if id == idDocType && string(v) != "webm" {
+			t.Fatalf("doc type %q", v)
+		}
+	}
+
+	id, segment, rest := readElement(t, rest)
+	if id != idSegment || len(rest) != 0 {
+		t.Fatalf("segment %x", id)
+	}
+
+	var clusters [][]uint64
+	for len(segment) > 0 {
+		id, payload, segment = readElement(t, segment)
+		switch id {
+		case idInfo:
+		case idTracks:
+			_, entry, _ := readElement(t, payload)
+			for len(entry) > 0 {
+				var v []byte
+				id, v, entry = readElement(t, entry)
+				if id == idCodecID && string(v) != "A_OPUS" {
+					t.Fatalf("codec %q", v)
+				}
+				if id == idCodecPrivate && !bytes.HasPrefix(v, []byte("OpusHead")) {
+					t.Fatalf("codec private %q", v)
+				}
+			}
+		case idCluster:
+			var timecodes []uint64
+			for len(payload) > 0 {
+				var v []byte
+				id, v, payload = readElement(t, payload)
+				switch id {
+				case idTimecode:
+					timecodes = append(timecodes, readUint(v))
+				case idSimpleBlock:
+					if v[0] != 0x81 || !bytes.Equal(v[4:], packet) {
+						t.Fatalf("block %x", v)
+					}
+					timecodes = append(timecodes, readUint(v[1:3]))
+				}
+			}
+			clusters = append(clusters, timecodes)
+		default:
+			t.Fatalf("element %x", id)
+		}
+	}
+
+	// the cluster timecode followed by the relative block timecodes,
+	// the second page starts 1s after the first one
+	want := [][]uint64{{0, 0, 20, 40}, {1000, 0, 20, 40, 60, 80, 100}}
+	if !slices.EqualFunc(clusters, want, slices.Equal) {
+		t.Fatalf("clusters %v, want %v", clusters, want)
+	}
+}
+
+func TestAppendSize(t *testing.T) {
+	for _, c := range []struct {
+		size uint64
+		want []byte
+	}{
+		{0, []byte{0x80}},
+		{126, []byte{0xfe}},
+		// 127 is the unknown size in 1 byte
+		{127, []byte{0x40, 0x7f}},
+		{1000, []byte{0x43, 0xe8}},
+	} {
+		if b := appendSize(nil, c.size); !bytes.Equal(b, c.want) {
+			t.Errorf("appendSize(%d) = %x, want %x", c.size, b, c.want)
+		}
+	}
+}